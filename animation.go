@@ -0,0 +1,106 @@
+package tmx
+
+import (
+	"sort"
+	"time"
+)
+
+// Animator finds the current frame of the animated tiles of a map. It covers
+// every tile with AnimationFrames in the map's tilesets, so the same GIDs work
+// for tiles placed in layers and for tile objects. Flipping flags in GIDs are
+// ignored.
+//
+// An Animator keeps its own clock, moved forward with Advance. FrameAt can be
+// used instead to find a frame for any time. An Animator must not be advanced
+// while it's used from other goroutines.
+type Animator struct {
+	animations map[uint32]*animation
+	elapsed    time.Duration
+}
+
+// animation is the frames of an animated tile with their durations summed, so
+// the frame at a time can be found with a binary search
+type animation struct {
+	frames []Frame
+	// ends are the times each frame ends, from the start of the animation
+	ends []time.Duration
+}
+
+// NewAnimator returns an Animator for the animated tiles of the map, with its
+// clock at 0
+func NewAnimator(m *Map) *Animator {
+	a := &Animator{
+		animations: make(map[uint32]*animation),
+	}
+	for _, ts := range m.Tilesets {
+		for _, t := range ts.Tiles {
+			if len(t.AnimationFrames) == 0 {
+				continue
+			}
+			an := &animation{
+				frames: t.AnimationFrames,
+				ends:   make([]time.Duration, len(t.AnimationFrames)),
+			}
+			var end time.Duration
+			for i, f := range t.AnimationFrames {
+				end += time.Duration(f.Duration * float64(time.Millisecond))
+				an.ends[i] = end
+			}
+			a.animations[ts.FirstGID+t.ID] = an
+		}
+	}
+	return a
+}
+
+// Animated returns whether the tile with the global tile ID gid is animated
+func (a *Animator) Animated(gid uint32) bool {
+	gid, _ = decodeGID(gid)
+	_, ok := a.animations[gid]
+	return ok
+}
+
+// FrameAt returns the local tile ID, within the tileset of gid, to draw for the
+// animated tile gid when its animation has been playing for t. Animations loop,
+// so t can be any time since the map was shown. It returns false if gid isn't
+// animated.
+func (a *Animator) FrameAt(gid uint32, t time.Duration) (uint32, bool) {
+	gid, _ = decodeGID(gid)
+	an, ok := a.animations[gid]
+	if !ok {
+		return 0, false
+	}
+	total := an.ends[len(an.ends)-1]
+	if total <= 0 {
+		return an.frames[0].TileID, true
+	}
+	t %= total
+	if t < 0 {
+		t += total
+	}
+	i := sort.Search(len(an.ends), func(i int) bool {
+		return an.ends[i] > t
+	})
+	return an.frames[i].TileID, true
+}
+
+// Frame returns the local tile ID to draw for the animated tile gid at the
+// animator's current time. It returns false if gid isn't animated.
+func (a *Animator) Frame(gid uint32) (uint32, bool) {
+	return a.FrameAt(gid, a.elapsed)
+}
+
+// Advance moves the animator's clock forward by dt, usually the time since the
+// last tick
+func (a *Animator) Advance(dt time.Duration) {
+	a.elapsed += dt
+}
+
+// Elapsed returns the time on the animator's clock
+func (a *Animator) Elapsed() time.Duration {
+	return a.elapsed
+}
+
+// Reset sets the animator's clock back to 0
+func (a *Animator) Reset() {
+	a.elapsed = 0
+}
@@ -0,0 +1,63 @@
+package tmx
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestAnimatorFrameAt(t *testing.T) {
+	m, err := ParseFS(os.DirFS("testData"), "animation.tmx")
+	if err != nil {
+		t.Fatalf("Unable to parse animation.tmx. Error was: %v", err)
+	}
+	a := NewAnimator(&m)
+	gid := m.Layers[0].Data[0].Tiles[1].GID
+	exp := []struct {
+		t  time.Duration
+		id uint32
+	}{
+		{0, 0},
+		{99 * time.Millisecond, 0},
+		{100 * time.Millisecond, 1},
+		{299 * time.Millisecond, 1},
+		{300 * time.Millisecond, 2},
+		{599 * time.Millisecond, 2},
+		{600 * time.Millisecond, 0},
+		{1900 * time.Millisecond, 1},
+		{-100 * time.Millisecond, 2},
+	}
+	for _, e := range exp {
+		id, ok := a.FrameAt(gid, e.t)
+		if !ok {
+			t.Fatalf("GID %v was not animated", gid)
+		}
+		if id != e.id {
+			t.Errorf("Frame at %v was incorrect\nWanted: %v\nGot: %v", e.t, e.id, id)
+		}
+	}
+	if _, ok := a.FrameAt(m.Layers[0].Data[0].Tiles[0].GID, 0); ok {
+		t.Errorf("Tile without animation frames was animated")
+	}
+}
+
+func TestAnimatorObject(t *testing.T) {
+	m, err := ParseFS(os.DirFS("testData"), "animation.tmx")
+	if err != nil {
+		t.Fatalf("Unable to parse animation.tmx. Error was: %v", err)
+	}
+	a := NewAnimator(&m)
+	gid := m.ObjectGroups[0].Objects[0].GID
+	if !a.Animated(gid) {
+		t.Fatalf("Flipped tile object GID %v was not animated", gid)
+	}
+	a.Advance(250 * time.Millisecond)
+	a.Advance(100 * time.Millisecond)
+	if id, _ := a.Frame(gid); id != 2 {
+		t.Errorf("Frame after advancing was incorrect\nWanted: %v\nGot: %v", 2, id)
+	}
+	a.Reset()
+	if id, _ := a.Frame(gid); id != 0 || a.Elapsed() != 0 {
+		t.Errorf("Frame after reset was incorrect\nWanted: %v\nGot: %v", 0, id)
+	}
+}
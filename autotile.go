@@ -0,0 +1,245 @@
+package tmx
+
+import (
+	"math/rand"
+)
+
+// Autotiler picks tiles from a wang set whose corners and edges match the
+// colors around them, the way Tiled's terrain brush does
+type Autotiler struct {
+	set      *WangSet
+	variants []wangVariant
+	// preferUntransformed uses untransformed tiles whenever one fits
+	preferUntransformed bool
+}
+
+// wangVariant is a wang tile as placed with some flipping flags
+type wangVariant struct {
+	id          WangID
+	tile        TileData
+	weight      float64
+	transformed bool
+}
+
+// flipFlags are every combination of the flipping flags
+var flipFlags = []uint32{
+	0,
+	HorizontalFlipFlag,
+	VerticalFlipFlag,
+	HorizontalFlipFlag | VerticalFlipFlag,
+	DiagonalFlipFlag,
+	DiagonalFlipFlag | HorizontalFlipFlag,
+	DiagonalFlipFlag | VerticalFlipFlag,
+	DiagonalFlipFlag | HorizontalFlipFlag | VerticalFlipFlag,
+}
+
+// NewAutotiler returns an Autotiler for the wang set of the tileset. If the
+// tileset's Transformations allow it, flipped and rotated variants of the wang
+// tiles are used too.
+//
+// Each tile is chosen with a weight of the tile's Probability multiplied by
+// the Probability of each color on its corners and edges.
+func NewAutotiler(ts *Tileset, set *WangSet) *Autotiler {
+	a := &Autotiler{
+		set: set,
+	}
+	transforms := []uint32{0}
+	if len(ts.Transformations) > 0 {
+		transforms = allowedFlips(ts.Transformations[0])
+		a.preferUntransformed = ts.Transformations[0].PreferUntransformed != 0
+	}
+	probability := make(map[uint32]float64, len(ts.Tiles))
+	for _, t := range ts.Tiles {
+		probability[t.ID] = t.Probability
+	}
+	for _, wt := range set.WangTiles {
+		weight := 1.0
+		if p, ok := probability[wt.TileID]; ok {
+			weight = p
+		}
+		base := wt.Flipping()
+		seen := make(map[WangID]bool)
+		for _, f := range transforms {
+			id := wt.WangID.Flip(f)
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			gid := ts.FirstGID + wt.TileID
+			flags := composeFlips(base, f)
+			a.variants = append(a.variants, wangVariant{
+				id:          id,
+				tile:        TileData{RawGID: gid | flags, GID: gid, Flipping: flags},
+				weight:      weight * a.colorWeight(id),
+				transformed: f != 0,
+			})
+		}
+	}
+	return a
+}
+
+// colorWeight returns the product of the probabilities of the colors of id
+func (a *Autotiler) colorWeight(id WangID) float64 {
+	w := 1.0
+	for _, c := range id {
+		if color, ok := a.set.Color(c); ok {
+			w *= color.Probability
+		}
+	}
+	return w
+}
+
+// allowedFlips returns the flipping flags the transformations can make
+func allowedFlips(t Transformations) []uint32 {
+	var generators []uint32
+	if t.HFlip != 0 {
+		generators = append(generators, HorizontalFlipFlag)
+	}
+	if t.VFlip != 0 {
+		generators = append(generators, VerticalFlipFlag)
+	}
+	if t.Rotate != 0 {
+		// A diagonal flip then a horizontal flip rotates 90 degrees clockwise
+		generators = append(generators, DiagonalFlipFlag|HorizontalFlipFlag)
+	}
+	flips := []uint32{0}
+	found := map[uint32]bool{0: true}
+	for i := 0; i < len(flips); i++ {
+		for _, g := range generators {
+			f := composeFlips(flips[i], g)
+			if !found[f] {
+				found[f] = true
+				flips = append(flips, f)
+			}
+		}
+	}
+	return flips
+}
+
+// composeFlips returns the flipping flags that have the same effect as
+// flipping with f and then with g
+func composeFlips(f, g uint32) uint32 {
+	// Every combination of flags moves the corners and edges of a wang ID
+	// differently, so the combination is found by where they end up
+	probe := WangID{1, 2, 3, 4, 5, 6, 7, 8}
+	want := probe.Flip(f).Flip(g)
+	for _, h := range flipFlags {
+		if probe.Flip(h) == want {
+			return h
+		}
+	}
+	return 0
+}
+
+// Tile returns a tile whose wang ID matches id. Indexes of 0 in id match any
+// color. If more than one tile matches, one is picked at random using r,
+// weighted by the tiles' probabilities. If r is nil the tile with the highest
+// weight is picked. It returns false if no tile matches.
+func (a *Autotiler) Tile(id WangID, r *rand.Rand) (TileData, bool) {
+	var candidates []*wangVariant
+	untransformed := false
+	for i := range a.variants {
+		v := &a.variants[i]
+		if !v.matches(id) {
+			continue
+		}
+		if a.preferUntransformed && !v.transformed && !untransformed {
+			// Drop the transformed tiles found so far
+			untransformed = true
+			candidates = candidates[:0]
+		}
+		if untransformed && v.transformed {
+			continue
+		}
+		candidates = append(candidates, v)
+	}
+	if len(candidates) == 0 {
+		return TileData{}, false
+	}
+	if r == nil {
+		best := candidates[0]
+		for _, v := range candidates[1:] {
+			if v.weight > best.weight {
+				best = v
+			}
+		}
+		return best.tile, true
+	}
+	total := 0.0
+	for _, v := range candidates {
+		total += v.weight
+	}
+	if total <= 0 {
+		return candidates[r.Intn(len(candidates))].tile, true
+	}
+	n := r.Float64() * total
+	for _, v := range candidates {
+		if n < v.weight {
+			return v.tile, true
+		}
+		n -= v.weight
+	}
+	return candidates[len(candidates)-1].tile, true
+}
+
+func (v *wangVariant) matches(id WangID) bool {
+	for i, c := range id {
+		if c != 0 && v.id[i] != c {
+			return false
+		}
+	}
+	return true
+}
+
+// Fill picks a tile for every cell of a grid from the colors of the corners
+// of the cells. corners is indexed by row and then column, and has one more
+// row and column than the grid of tiles it returns. Corners with a color of 0
+// match any color.
+//
+// Corner sets match the corners of the tiles. Edge sets match the edges,
+// where an edge has the color of the corners at its ends if they are the
+// same, and matches any color otherwise. Mixed sets match both. Cells without
+// a matching tile are left empty, with a GID of 0.
+func (a *Autotiler) Fill(corners [][]uint8, r *rand.Rand) [][]TileData {
+	if len(corners) < 2 {
+		return nil
+	}
+	tiles := make([][]TileData, len(corners)-1)
+	for y := range tiles {
+		w := len(corners[y]) - 1
+		if len(corners[y+1])-1 < w {
+			w = len(corners[y+1]) - 1
+		}
+		if w < 0 {
+			w = 0
+		}
+		tiles[y] = make([]TileData, w)
+		for x := range tiles[y] {
+			tiles[y][x], _ = a.Tile(a.cellID(corners[y][x], corners[y][x+1], corners[y+1][x+1], corners[y+1][x]), r)
+		}
+	}
+	return tiles
+}
+
+// cellID returns the wang ID a cell needs to fit its corners, given clockwise
+// from the top left
+func (a *Autotiler) cellID(tl, tr, br, bl uint8) WangID {
+	var id WangID
+	if a.set.Type != "edge" {
+		id[WangTopRight], id[WangBottomRight], id[WangBottomLeft], id[WangTopLeft] = tr, br, bl, tl
+	}
+	if a.set.Type != "corner" {
+		id[WangTop] = sameColor(tl, tr)
+		id[WangRight] = sameColor(tr, br)
+		id[WangBottom] = sameColor(br, bl)
+		id[WangLeft] = sameColor(bl, tl)
+	}
+	return id
+}
+
+func sameColor(a, b uint8) uint8 {
+	if a == b {
+		return a
+	}
+	return 0
+}
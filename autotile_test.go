@@ -0,0 +1,115 @@
+package tmx
+
+import (
+	"math/rand"
+	"os"
+	"testing"
+)
+
+func groundAutotiler(t *testing.T) *Autotiler {
+	m, err := ParseFS(os.DirFS("testData"), "wangSets.tmx")
+	if err != nil {
+		t.Fatalf("Unable to parse wangSets.tmx. Error was: %v", err)
+	}
+	return NewAutotiler(&m.Tilesets[0], &m.Tilesets[0].WangSets[0])
+}
+
+func TestAutotilerTile(t *testing.T) {
+	a := groundAutotiler(t)
+	exp := []struct {
+		id       WangID
+		gid      uint32
+		flipping uint32
+	}{
+		// Tile 3 has the highest probability of the all grass tiles
+		{WangID{0, 1, 0, 1, 0, 1, 0, 1}, 4, 0},
+		{WangID{0, 2, 0, 2, 0, 2, 0, 2}, 2, 0},
+		{WangID{0, 2, 0, 1, 0, 1, 0, 1}, 3, 0},
+		{WangID{0, 1, 0, 2, 0, 1, 0, 1}, 3, DiagonalFlipFlag | HorizontalFlipFlag},
+		{WangID{0, 1, 0, 1, 0, 2, 0, 1}, 3, HorizontalFlipFlag | VerticalFlipFlag},
+		{WangID{0, 1, 0, 1, 0, 1, 0, 2}, 3, DiagonalFlipFlag | VerticalFlipFlag},
+		{WangID{0, 2, 0, 0, 0, 0, 0, 0}, 3, 0},
+	}
+	for _, e := range exp {
+		tile, ok := a.Tile(e.id, nil)
+		if !ok {
+			t.Errorf("No tile found for %v", e.id)
+			continue
+		}
+		if tile.GID != e.gid || tile.Flipping != e.flipping || tile.RawGID != e.gid|e.flipping {
+			t.Errorf("Wrong tile found for %v\nWanted: %v %x\nGot: %v %x", e.id, e.gid, e.flipping, tile.GID, tile.Flipping)
+		}
+	}
+	if tile, ok := a.Tile(WangID{0, 2, 0, 2, 0, 1, 0, 1}, nil); ok {
+		t.Errorf("Found a tile for a wang ID that has none\nGot: %v", tile)
+	}
+}
+
+func TestAutotilerProbability(t *testing.T) {
+	a := groundAutotiler(t)
+	r := rand.New(rand.NewSource(1))
+	counts := make(map[uint32]int)
+	for i := 0; i < 1000; i++ {
+		tile, _ := a.Tile(WangID{0, 1, 0, 1, 0, 1, 0, 1}, r)
+		counts[tile.GID]++
+	}
+	// Tile 3 has a probability of 3 and tile 0 of 1
+	if counts[4] < 700 || counts[4] > 800 || counts[1]+counts[4] != 1000 {
+		t.Errorf("Tiles weren't picked by their probability\nWanted: about %v of %v\nGot: %v", 750, 1000, counts)
+	}
+}
+
+func TestAutotilerPreferUntransformed(t *testing.T) {
+	ts := Tileset{
+		FirstGID:        1,
+		Transformations: []Transformations{{Rotate: 1, PreferUntransformed: 1}},
+	}
+	set := WangSet{
+		Type:   "corner",
+		Colors: []WangColor{{Probability: 1}, {Probability: 1}},
+		WangTiles: []WangTile{
+			{TileID: 0, WangID: WangID{0, 2, 0, 1, 0, 1, 0, 1}},
+			{TileID: 1, WangID: WangID{0, 1, 0, 1, 0, 1, 0, 2}},
+		},
+	}
+	r := rand.New(rand.NewSource(1))
+	for _, prefer := range []int{1, 0} {
+		ts.Transformations[0].PreferUntransformed = prefer
+		a := NewAutotiler(&ts, &set)
+		counts := make(map[uint32]int)
+		for i := 0; i < 100; i++ {
+			tile, _ := a.Tile(WangID{0, 1, 0, 1, 0, 1, 0, 2}, r)
+			counts[tile.RawGID]++
+		}
+		if prefer == 1 && counts[2] != 100 {
+			t.Errorf("Transformed tiles were used when an untransformed tile fit\nGot: %v", counts)
+		}
+		if prefer == 0 && (counts[2] == 0 || counts[1|DiagonalFlipFlag|VerticalFlipFlag] == 0) {
+			t.Errorf("Transformed tiles weren't used\nGot: %v", counts)
+		}
+	}
+}
+
+func TestAutotilerFill(t *testing.T) {
+	a := groundAutotiler(t)
+	tiles := a.Fill([][]uint8{
+		{1, 1, 1},
+		{1, 1, 2},
+		{1, 1, 1},
+	}, nil)
+	exp := [][]uint32{
+		{4, 3 | DiagonalFlipFlag | HorizontalFlipFlag},
+		{4, 3},
+	}
+	if len(tiles) != len(exp) {
+		t.Fatalf("Filled the wrong number of rows\nWanted: %v\nGot: %v", len(exp), len(tiles))
+	}
+	for y := range exp {
+		for x := range exp[y] {
+			if x >= len(tiles[y]) || tiles[y][x].RawGID != exp[y][x] {
+				t.Errorf("Filled the wrong tiles\nWanted: %v\nGot: %v", exp, tiles)
+				return
+			}
+		}
+	}
+}
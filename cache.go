@@ -0,0 +1,133 @@
+package tmx
+
+import (
+	"path"
+	"sync"
+)
+
+// loaderCache holds the external tilesets and templates a Loader has read,
+// keyed by their location in the Loader's file system
+type loaderCache struct {
+	mu        sync.Mutex
+	tilesets  map[string]*Tileset
+	templates map[string]*cachedTemplate
+	// gen counts the calls to Invalidate and InvalidateAll. Files read while
+	// it changed may be out of date, so they aren't stored.
+	gen uint64
+}
+
+// cachedTemplate is a template along with the locations of the external
+// tilesets it uses, so it's dropped when one of them changes
+type cachedTemplate struct {
+	tmpl     *Template
+	tilesets []string
+}
+
+// tileset returns the external tileset stored in the file src, reading it
+// if it isn't cached yet. The tileset is shared and must not be modified.
+func (l *Loader) tileset(src string) (*Tileset, error) {
+	c := &l.cache
+	c.mu.Lock()
+	t, ok := c.tilesets[src]
+	gen := c.gen
+	c.mu.Unlock()
+	if ok {
+		return t, nil
+	}
+	t = &Tileset{}
+	err := l.decodeFile(src, t)
+	if err == nil {
+		err = l.resolveTileset(t, src, "")
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cached, ok := c.tilesets[src]; ok {
+		// Another Parse read it at the same time
+		return cached, nil
+	}
+	if c.gen != gen {
+		return t, nil
+	}
+	if c.tilesets == nil {
+		c.tilesets = make(map[string]*Tileset)
+	}
+	c.tilesets[src] = t
+	return t, nil
+}
+
+// template returns the template stored in the file src, with its tilesets
+// resolved, reading it if it isn't cached yet. The template is shared and
+// must not be modified.
+func (l *Loader) template(src string) (*Template, error) {
+	c := &l.cache
+	c.mu.Lock()
+	ct, ok := c.templates[src]
+	gen := c.gen
+	c.mu.Unlock()
+	if ok {
+		return ct.tmpl, nil
+	}
+	tmpl := &Template{}
+	err := l.decodeFile(src, tmpl)
+	if err == nil {
+		err = l.resolveTemplate(tmpl, src)
+	}
+	if err != nil {
+		return nil, err
+	}
+	ct = &cachedTemplate{tmpl: tmpl}
+	for _, t := range tmpl.Tilesets {
+		if t.Source != "" {
+			ct.tilesets = append(ct.tilesets, path.Join(path.Dir(src), t.Source))
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cached, ok := c.templates[src]; ok {
+		return cached.tmpl, nil
+	}
+	if c.gen != gen {
+		return tmpl, nil
+	}
+	if c.templates == nil {
+		c.templates = make(map[string]*cachedTemplate)
+	}
+	c.templates[src] = ct
+	return tmpl, nil
+}
+
+// Invalidate drops the external tileset or template in the file name from the
+// Loader's cache, so the next map that uses it reads it again. Templates that
+// use a dropped tileset are dropped too. Editors can call it when a file
+// changes on disk. Maps that were already parsed keep what they read.
+func (l *Loader) Invalidate(name string) {
+	name = path.Clean(name)
+	c := &l.cache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	delete(c.tilesets, name)
+	delete(c.templates, name)
+	for src, ct := range c.templates {
+		for _, t := range ct.tilesets {
+			if t == name {
+				delete(c.templates, src)
+				break
+			}
+		}
+	}
+}
+
+// InvalidateAll drops every external tileset and template from the Loader's
+// cache
+func (l *Loader) InvalidateAll() {
+	c := &l.cache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	c.tilesets = nil
+	c.templates = nil
+}
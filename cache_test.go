@@ -0,0 +1,182 @@
+package tmx
+
+import (
+	"io/fs"
+	"sync"
+	"testing"
+	"testing/fstest"
+)
+
+// countFS counts how many times each file is opened
+type countFS struct {
+	fstest.MapFS
+	mu    sync.Mutex
+	opens map[string]int
+}
+
+func (c *countFS) Open(name string) (fs.File, error) {
+	c.mu.Lock()
+	c.opens[name]++
+	c.mu.Unlock()
+	return c.MapFS.Open(name)
+}
+
+func (c *countFS) count(name string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.opens[name]
+}
+
+func cacheFS() *countFS {
+	return &countFS{
+		opens: make(map[string]int),
+		MapFS: fstest.MapFS{
+			"maps/a.tmx": &fstest.MapFile{Data: []byte(`<map width="1" height="1" tilewidth="16" tileheight="16">
+ <tileset firstgid="1" source="../tilesets/props.tsx"/>
+ <objectgroup name="Objects">
+  <object id="1" template="../templates/barrel.tx"/>
+  <object id="2" template="../templates/barrel.tx" x="16"/>
+  <object id="3" template="../templates/barrel.tx" x="32"/>
+ </objectgroup>
+</map>`)},
+			"maps/b.tmx": &fstest.MapFile{Data: []byte(`<map width="1" height="1" tilewidth="16" tileheight="16">
+ <tileset firstgid="1" source="../tilesets/props.tsx"/>
+ <objectgroup name="Objects">
+  <object id="1" template="../templates/barrel.tx"/>
+ </objectgroup>
+</map>`)},
+			"tilesets/props.tsx": &fstest.MapFile{Data: []byte(`<tileset name="props" tilewidth="16" tileheight="16" tilecount="4" columns="4">
+ <image source="props.png" width="64" height="16"/>
+</tileset>`)},
+			"templates/barrel.tx": &fstest.MapFile{Data: []byte(`<template>
+ <tileset firstgid="1" source="../tilesets/props.tsx"/>
+ <object name="Barrel" gid="2" width="16" height="16"/>
+</template>`)},
+		},
+	}
+}
+
+func TestLoaderCache(t *testing.T) {
+	fsys := cacheFS()
+	l := NewLoader(fsys)
+	for _, name := range []string{"maps/a.tmx", "maps/b.tmx", "maps/a.tmx"} {
+		m, err := l.Parse(name)
+		if err != nil {
+			t.Fatalf("Unable to parse %v. Error was: %v", name, err)
+		}
+		if o := m.ObjectGroups[0].Objects[0]; o.Name != "Barrel" || o.GID != 2 {
+			t.Errorf("Template was not applied from the cache\nWanted: %v %v\nGot: %v %v", "Barrel", 2, o.Name, o.GID)
+		}
+		if m.Tilesets[0].Name != "props" {
+			t.Errorf("Tileset was not resolved from the cache\nWanted: %v\nGot: %v", "props", m.Tilesets[0].Name)
+		}
+	}
+	for _, name := range []string{"tilesets/props.tsx", "templates/barrel.tx"} {
+		if n := fsys.count(name); n != 1 {
+			t.Errorf("%v was read more than once\nWanted: %v\nGot: %v", name, 1, n)
+		}
+	}
+}
+
+func TestLoaderInvalidate(t *testing.T) {
+	fsys := cacheFS()
+	l := NewLoader(fsys)
+	if _, err := l.Parse("maps/b.tmx"); err != nil {
+		t.Fatalf("Unable to parse maps/b.tmx. Error was: %v", err)
+	}
+	fsys.MapFS["templates/barrel.tx"] = &fstest.MapFile{Data: []byte(`<template>
+ <object name="Crate" width="16" height="16"/>
+</template>`)}
+	l.Invalidate("templates/barrel.tx")
+	m, err := l.Parse("maps/b.tmx")
+	if err != nil {
+		t.Fatalf("Unable to parse maps/b.tmx. Error was: %v", err)
+	}
+	if name := m.ObjectGroups[0].Objects[0].Name; name != "Crate" {
+		t.Errorf("Changed template was not read again\nWanted: %v\nGot: %v", "Crate", name)
+	}
+	if n := fsys.count("tilesets/props.tsx"); n != 1 {
+		t.Errorf("Unchanged tileset was read again\nWanted: %v\nGot: %v", 1, n)
+	}
+
+	// Dropping a tileset drops the templates that use it
+	fsys.MapFS["templates/barrel.tx"] = &fstest.MapFile{Data: []byte(`<template>
+ <tileset firstgid="1" source="../tilesets/props.tsx"/>
+ <object name="Barrel" gid="2" width="16" height="16"/>
+</template>`)}
+	l.InvalidateAll()
+	if _, err = l.Parse("maps/b.tmx"); err != nil {
+		t.Fatalf("Unable to parse maps/b.tmx. Error was: %v", err)
+	}
+	l.Invalidate("tilesets/../tilesets/props.tsx")
+	if _, err = l.Parse("maps/b.tmx"); err != nil {
+		t.Fatalf("Unable to parse maps/b.tmx. Error was: %v", err)
+	}
+	if n := fsys.count("templates/barrel.tx"); n != 4 {
+		t.Errorf("Template using a dropped tileset was not read again\nWanted: %v\nGot: %v", 4, n)
+	}
+}
+
+// openHookFS calls hook before opening a file
+type openHookFS struct {
+	*countFS
+	hook func(name string)
+}
+
+func (h *openHookFS) Open(name string) (fs.File, error) {
+	if h.hook != nil {
+		h.hook(name)
+	}
+	return h.countFS.Open(name)
+}
+
+func TestLoaderInvalidateWhileReading(t *testing.T) {
+	fsys := &openHookFS{countFS: cacheFS()}
+	l := NewLoader(fsys)
+	// The template changes while it's being read, so what was read mustn't be
+	// cached
+	fsys.hook = func(name string) {
+		if name == "templates/barrel.tx" {
+			l.Invalidate(name)
+		}
+	}
+	if _, err := l.Parse("maps/b.tmx"); err != nil {
+		t.Fatalf("Unable to parse maps/b.tmx. Error was: %v", err)
+	}
+	fsys.hook = nil
+	if _, err := l.Parse("maps/b.tmx"); err != nil {
+		t.Fatalf("Unable to parse maps/b.tmx. Error was: %v", err)
+	}
+	if n := fsys.count("templates/barrel.tx"); n != 2 {
+		t.Errorf("Template invalidated while reading was cached\nWanted: %v\nGot: %v", 2, n)
+	}
+	if _, err := l.Parse("maps/b.tmx"); err != nil {
+		t.Fatalf("Unable to parse maps/b.tmx. Error was: %v", err)
+	}
+	if n := fsys.count("templates/barrel.tx"); n != 2 {
+		t.Errorf("Template was not cached after reading it again\nWanted: %v\nGot: %v", 2, n)
+	}
+}
+
+func TestLoaderCacheConcurrent(t *testing.T) {
+	fsys := cacheFS()
+	l := NewLoader(fsys)
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = l.Parse("maps/a.tmx")
+			if i%2 == 0 {
+				l.Invalidate("templates/barrel.tx")
+			}
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			t.Errorf("Unable to parse maps/a.tmx concurrently. Error was: %v", err)
+		}
+	}
+}
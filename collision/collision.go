@@ -0,0 +1,276 @@
+// Package collision turns the collision shapes drawn in Tiled's tile collision
+// editor into world space geometry for the tiles of a layer.
+package collision
+
+import (
+	"image"
+	"math"
+
+	"github.com/Noofbiz/tmx"
+)
+
+// Shape is a collision shape in world space, in pixels
+type Shape struct {
+	// Kind is tmx.ShapeRectangle, tmx.ShapeEllipse or tmx.ShapePolygon
+	Kind tmx.ShapeKind
+	// X and Y are the top left of the bounding box of the shape
+	X, Y float64
+	// Width and Height are the size of the bounding box of the shape
+	Width, Height float64
+	// Points are the vertices of polygons
+	Points tmx.Points
+	// Object is the collision object the shape was made from. It is nil for
+	// rectangles made by merging solid tiles.
+	Object *tmx.Object
+	// TileX and TileY are the coordinates of the tile the shape belongs to.
+	// Merged rectangles have the coordinates of their top left tile.
+	TileX, TileY int
+}
+
+// Options change how the shapes of a layer are found
+type Options struct {
+	// Merge joins neighbouring solid tiles into as few rectangles as possible.
+	// A tile is solid if its only collision shape is a rectangle covering its
+	// whole cell. Tiles are only merged on orthogonal maps.
+	Merge bool
+}
+
+// ellipseSegments is the number of sides of the polygons rotated ellipses are
+// turned into
+const ellipseSegments = 16
+
+// Layer returns the collision shapes of the tiles in the layer of the map, in
+// world space. Each tile's shapes are moved to where the tile is drawn: its
+// cell, aligned to the bottom left like Tiled draws tiles and scaled to the
+// tileset's render size, plus the layer's offset, with those of the groups
+// it's in as given by tmx.Map.Effective, and the tileset's tile offset.
+//
+// Shapes of flipped tiles are mirrored along with the tile. Rotated rectangles
+// and polygons become polygons, and rotated ellipses become polygons with 16
+// sides. Points, polylines, capsules, text and tile objects aren't collision
+// shapes and are left out.
+func Layer(m *tmx.Map, l *tmx.Layer, opts Options) []Shape {
+	var shapes []Shape
+	var solid [][2]int
+	ox, oy := l.OffsetX, l.OffsetY
+	if e, ok := m.Effective(l); ok {
+		ox, oy = e.OffsetX, e.OffsetY
+	}
+	l.EachTile("", func(x, y int, t tmx.TileData) {
+		info, ok := m.TileForGID(t.GID)
+		if !ok || info.Tile == nil || len(info.Tile.ObjectGroup) == 0 {
+			return
+		}
+		cx, cy := m.TileToPixel(x, y)
+		// The size of the tile's image, before and after flipping
+		tw, th := float64(info.Tileset.TileWidth), float64(info.Tileset.TileHeight)
+		if info.Image != nil && !info.Rect.Empty() {
+			tw, th = float64(info.Rect.Dx()), float64(info.Rect.Dy())
+		}
+		fw, fh := tw, th
+		if t.Flipping&tmx.DiagonalFlipFlag != 0 {
+			fw, fh = th, tw
+		}
+		// The size of the tile as drawn, to align it to the bottom of its cell
+		dw, dh := info.Tileset.RenderSize(image.Rect(0, 0, int(fw), int(fh)), m.TileWidth, m.TileHeight)
+		tr := transform{
+			flags: t.Flipping,
+			w:     tw,
+			h:     th,
+			sx:    dw / fw,
+			sy:    dh / fh,
+			x:     cx + ox,
+			y:     cy + float64(m.TileHeight) - dh + oy,
+		}
+		if info.Tileset.TileRenderSize == "grid" {
+			tr.x += (float64(m.TileWidth) - dw) / 2
+			tr.y -= (float64(m.TileHeight) - dh) / 2
+		}
+		if len(info.Tileset.TileOffset) > 0 {
+			tr.x += info.Tileset.TileOffset[0].X
+			tr.y += info.Tileset.TileOffset[0].Y
+		}
+		var tileShapes []Shape
+		for _, og := range info.Tile.ObjectGroup {
+			for i := range og.Objects {
+				if s, ok := tr.shape(&og.Objects[i]); ok {
+					s.TileX, s.TileY = x, y
+					tileShapes = append(tileShapes, s)
+				}
+			}
+		}
+		if opts.Merge && isOrthogonal(m) && len(tileShapes) == 1 && covers(tileShapes[0], cx+ox, cy+oy, m) {
+			solid = append(solid, [2]int{x, y})
+			return
+		}
+		shapes = append(shapes, tileShapes...)
+	})
+	return append(shapes, merge(solid, m, ox, oy)...)
+}
+
+func isOrthogonal(m *tmx.Map) bool {
+	return m.Orientation == "" || m.Orientation == "orthogonal"
+}
+
+// covers returns whether the shape is a rectangle filling the cell at x, y
+func covers(s Shape, x, y float64, m *tmx.Map) bool {
+	const e = 1e-9
+	return s.Kind == tmx.ShapeRectangle &&
+		math.Abs(s.X-x) < e && math.Abs(s.Y-y) < e &&
+		math.Abs(s.Width-float64(m.TileWidth)) < e && math.Abs(s.Height-float64(m.TileHeight)) < e
+}
+
+// merge joins the solid cells, given in right-down order, into rectangles.
+// Each rectangle is grown as far right as it can go, then down for as long as
+// every cell below it is solid. ox, oy is the offset of the layer.
+func merge(cells [][2]int, m *tmx.Map, ox, oy float64) []Shape {
+	if len(cells) == 0 {
+		return nil
+	}
+	var shapes []Shape
+	solid := make(map[[2]int]bool, len(cells))
+	for _, c := range cells {
+		solid[c] = true
+	}
+	used := make(map[[2]int]bool, len(cells))
+	free := func(x, y int) bool {
+		return solid[[2]int{x, y}] && !used[[2]int{x, y}]
+	}
+	for _, c := range cells {
+		x, y := c[0], c[1]
+		if !free(x, y) {
+			continue
+		}
+		w := 1
+		for free(x+w, y) {
+			w++
+		}
+		h := 1
+	grow:
+		for {
+			for i := 0; i < w; i++ {
+				if !free(x+i, y+h) {
+					break grow
+				}
+			}
+			h++
+		}
+		for j := 0; j < h; j++ {
+			for i := 0; i < w; i++ {
+				used[[2]int{x + i, y + j}] = true
+			}
+		}
+		px, py := m.TileToPixel(x, y)
+		shapes = append(shapes, Shape{
+			Kind:   tmx.ShapeRectangle,
+			X:      px + ox,
+			Y:      py + oy,
+			Width:  float64(w * m.TileWidth),
+			Height: float64(h * m.TileHeight),
+			TileX:  x,
+			TileY:  y,
+		})
+	}
+	return shapes
+}
+
+// transform moves points from a tile's collision editor into world space. The
+// tile is w by h pixels before flipping, it's scaled by sx, sy after flipping,
+// and its top left is drawn at x, y.
+type transform struct {
+	flags      uint32
+	w, h, x, y float64
+	sx, sy     float64
+}
+
+// point flips the point the way Tiled flips the tile, with the diagonal flip
+// done before the horizontal and vertical flips, scales it to the size the
+// tile is drawn at and moves it to the tile
+func (t transform) point(p tmx.Point) tmx.Point {
+	w, h := t.w, t.h
+	if t.flags&tmx.DiagonalFlipFlag != 0 {
+		p.X, p.Y = p.Y, p.X
+		w, h = h, w
+	}
+	if t.flags&tmx.HorizontalFlipFlag != 0 {
+		p.X = w - p.X
+	}
+	if t.flags&tmx.VerticalFlipFlag != 0 {
+		p.Y = h - p.Y
+	}
+	return tmx.Point{X: t.x + p.X*t.sx, Y: t.y + p.Y*t.sy}
+}
+
+// mirrored returns whether the flips reverse the winding of polygons
+func (t transform) mirrored() bool {
+	n := 0
+	for _, f := range []uint32{tmx.HorizontalFlipFlag, tmx.VerticalFlipFlag, tmx.DiagonalFlipFlag} {
+		if t.flags&f != 0 {
+			n++
+		}
+	}
+	return n%2 == 1
+}
+
+// shape returns the world space shape of the collision object
+func (t transform) shape(o *tmx.Object) (Shape, bool) {
+	s := o.Shape()
+	switch s.Kind {
+	case tmx.ShapeRectangle:
+		if o.Rotation != 0 {
+			return t.polygon(o, tmx.Points{{X: 0, Y: 0}, {X: o.Width, Y: 0}, {X: o.Width, Y: o.Height}, {X: 0, Y: o.Height}}), true
+		}
+		return t.box(o, tmx.ShapeRectangle), true
+	case tmx.ShapeEllipse:
+		if o.Rotation != 0 {
+			ps := make(tmx.Points, ellipseSegments)
+			for i := range ps {
+				sin, cos := math.Sincos(2 * math.Pi * float64(i) / ellipseSegments)
+				ps[i] = tmx.Point{X: o.Width / 2 * (1 + cos), Y: o.Height / 2 * (1 + sin)}
+			}
+			return t.polygon(o, ps), true
+		}
+		return t.box(o, tmx.ShapeEllipse), true
+	case tmx.ShapePolygon:
+		return t.polygon(o, o.Polygons[0].Points), true
+	}
+	return Shape{}, false
+}
+
+// box returns the unrotated rectangle or ellipse of the object
+func (t transform) box(o *tmx.Object, kind tmx.ShapeKind) Shape {
+	a := t.point(tmx.Point{X: o.X, Y: o.Y})
+	b := t.point(tmx.Point{X: o.X + o.Width, Y: o.Y + o.Height})
+	return Shape{
+		Kind:   kind,
+		X:      math.Min(a.X, b.X),
+		Y:      math.Min(a.Y, b.Y),
+		Width:  math.Abs(b.X - a.X),
+		Height: math.Abs(b.Y - a.Y),
+		Object: o,
+	}
+}
+
+// polygon returns the polygon with the vertices ps, relative to the object
+func (t transform) polygon(o *tmx.Object, ps tmx.Points) Shape {
+	s := Shape{
+		Kind:   tmx.ShapePolygon,
+		Points: make(tmx.Points, len(ps)),
+		Object: o,
+	}
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for i, p := range ps {
+		j := i
+		if t.mirrored() {
+			// Keep the winding of the polygon the same as in Tiled
+			j = len(ps) - 1 - i
+		}
+		w := t.point(o.ToWorld(p))
+		s.Points[j] = w
+		minX, minY = math.Min(minX, w.X), math.Min(minY, w.Y)
+		maxX, maxY = math.Max(maxX, w.X), math.Max(maxY, w.Y)
+	}
+	s.X, s.Y, s.Width, s.Height = minX, minY, maxX-minX, maxY-minY
+	return s
+}
@@ -0,0 +1,185 @@
+package collision
+
+import (
+	"strconv"
+	"testing"
+	"testing/fstest"
+
+	"github.com/Noofbiz/tmx"
+)
+
+func parse(t *testing.T) tmx.Map {
+	h := strconv.FormatUint(uint64(tmx.HorizontalFlipFlag|4), 10)
+	d := strconv.FormatUint(uint64(tmx.DiagonalFlipFlag|4), 10)
+	v := strconv.FormatUint(uint64(tmx.VerticalFlipFlag|3), 10)
+	fsys := fstest.MapFS{
+		"map.tmx": &fstest.MapFile{Data: []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<map version="1.2" orientation="orthogonal" width="4" height="2" tilewidth="16" tileheight="16">
+ <tileset firstgid="1" name="tiles" tilewidth="16" tileheight="16" tilecount="4" columns="4">
+  <image source="tiles.png" width="64" height="16"/>
+  <tile id="0">
+   <objectgroup draworder="index">
+    <object id="1" x="0" y="0" width="16" height="16"/>
+   </objectgroup>
+  </tile>
+  <tile id="1">
+   <objectgroup draworder="index">
+    <object id="1" x="0" y="0">
+     <polygon points="0,0 16,0 0,16"/>
+    </object>
+   </objectgroup>
+  </tile>
+  <tile id="2">
+   <objectgroup draworder="index">
+    <object id="1" x="2" y="4" width="8" height="6">
+     <ellipse/>
+    </object>
+   </objectgroup>
+  </tile>
+  <tile id="3">
+   <objectgroup draworder="index">
+    <object id="1" x="0" y="0" width="4" height="16"/>
+   </objectgroup>
+  </tile>
+ </tileset>
+ <layer name="Ground" width="4" height="2">
+  <data encoding="csv">1,1,` + h + `,` + d + `,1,2,3,` + v + `</data>
+ </layer>
+</map>`)},
+	}
+	m, err := tmx.ParseFS(fsys, "map.tmx")
+	if err != nil {
+		t.Fatalf("Unable to parse map. Error was: %v", err)
+	}
+	return m
+}
+
+func checkShapes(t *testing.T, got, exp []Shape) {
+	t.Helper()
+	if len(got) != len(exp) {
+		t.Fatalf("Wrong number of shapes\nWanted: %v\nGot: %v", len(exp), len(got))
+	}
+	for i, e := range exp {
+		g := got[i]
+		if g.Kind != e.Kind || g.X != e.X || g.Y != e.Y || g.Width != e.Width || g.Height != e.Height || g.TileX != e.TileX || g.TileY != e.TileY {
+			t.Errorf("Shape %v was incorrect\nWanted: %v %v,%v %vx%v at tile %v,%v\nGot: %v %v,%v %vx%v at tile %v,%v", i,
+				e.Kind, e.X, e.Y, e.Width, e.Height, e.TileX, e.TileY, g.Kind, g.X, g.Y, g.Width, g.Height, g.TileX, g.TileY)
+		}
+		if len(g.Points) != len(e.Points) {
+			t.Errorf("Shape %v has the wrong points\nWanted: %v\nGot: %v", i, e.Points, g.Points)
+			continue
+		}
+		for j := range e.Points {
+			if g.Points[j] != e.Points[j] {
+				t.Errorf("Shape %v has the wrong points\nWanted: %v\nGot: %v", i, e.Points, g.Points)
+				break
+			}
+		}
+	}
+}
+
+func TestLayer(t *testing.T) {
+	m := parse(t)
+	got := Layer(&m, &m.Layers[0], Options{})
+	checkShapes(t, got, []Shape{
+		{Kind: tmx.ShapeRectangle, X: 0, Y: 0, Width: 16, Height: 16, TileX: 0, TileY: 0},
+		{Kind: tmx.ShapeRectangle, X: 16, Y: 0, Width: 16, Height: 16, TileX: 1, TileY: 0},
+		{Kind: tmx.ShapeRectangle, X: 44, Y: 0, Width: 4, Height: 16, TileX: 2, TileY: 0},
+		{Kind: tmx.ShapeRectangle, X: 48, Y: 0, Width: 16, Height: 4, TileX: 3, TileY: 0},
+		{Kind: tmx.ShapeRectangle, X: 0, Y: 16, Width: 16, Height: 16, TileX: 0, TileY: 1},
+		{Kind: tmx.ShapePolygon, X: 16, Y: 16, Width: 16, Height: 16, TileX: 1, TileY: 1,
+			Points: tmx.Points{{X: 16, Y: 16}, {X: 32, Y: 16}, {X: 16, Y: 32}}},
+		{Kind: tmx.ShapeEllipse, X: 34, Y: 20, Width: 8, Height: 6, TileX: 2, TileY: 1},
+		{Kind: tmx.ShapeEllipse, X: 50, Y: 22, Width: 8, Height: 6, TileX: 3, TileY: 1},
+	})
+	if got[0].Object == nil || got[0].Object.ID != 1 {
+		t.Errorf("Shape does not refer to its collision object")
+	}
+}
+
+func TestLayerMerge(t *testing.T) {
+	m := parse(t)
+	got := Layer(&m, &m.Layers[0], Options{Merge: true})
+	checkShapes(t, got, []Shape{
+		{Kind: tmx.ShapeRectangle, X: 44, Y: 0, Width: 4, Height: 16, TileX: 2, TileY: 0},
+		{Kind: tmx.ShapeRectangle, X: 48, Y: 0, Width: 16, Height: 4, TileX: 3, TileY: 0},
+		{Kind: tmx.ShapePolygon, X: 16, Y: 16, Width: 16, Height: 16, TileX: 1, TileY: 1,
+			Points: tmx.Points{{X: 16, Y: 16}, {X: 32, Y: 16}, {X: 16, Y: 32}}},
+		{Kind: tmx.ShapeEllipse, X: 34, Y: 20, Width: 8, Height: 6, TileX: 2, TileY: 1},
+		{Kind: tmx.ShapeEllipse, X: 50, Y: 22, Width: 8, Height: 6, TileX: 3, TileY: 1},
+		{Kind: tmx.ShapeRectangle, X: 0, Y: 0, Width: 32, Height: 16, TileX: 0, TileY: 0},
+		{Kind: tmx.ShapeRectangle, X: 0, Y: 16, Width: 16, Height: 16, TileX: 0, TileY: 1},
+	})
+}
+
+func TestLayerFlippedPolygon(t *testing.T) {
+	m := parse(t)
+	m.Layers[0].Data[0].Tiles[5].Flipping = tmx.HorizontalFlipFlag
+	got := Layer(&m, &m.Layers[0], Options{})
+	// Mirroring reverses the order of the points to keep their winding
+	checkShapes(t, got[5:6], []Shape{
+		{Kind: tmx.ShapePolygon, X: 16, Y: 16, Width: 16, Height: 16, TileX: 1, TileY: 1,
+			Points: tmx.Points{{X: 32, Y: 32}, {X: 16, Y: 16}, {X: 32, Y: 16}}},
+	})
+}
+
+func TestLayerRenderSize(t *testing.T) {
+	fsys := fstest.MapFS{
+		"map.tmx": &fstest.MapFile{Data: []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<map version="1.10" orientation="orthogonal" width="2" height="1" tilewidth="16" tileheight="16">
+ <tileset firstgid="1" name="collection" tilewidth="32" tileheight="64" tilecount="1" columns="0" tilerendersize="grid" fillmode="preserve-aspect-fit">
+  <tile id="0">
+   <image source="tall.png" width="32" height="64"/>
+   <objectgroup draworder="index">
+    <object id="1" x="0" y="32" width="32" height="32"/>
+   </objectgroup>
+  </tile>
+ </tileset>
+ <layer name="Ground" width="2" height="1">
+  <data encoding="csv">0,1</data>
+ </layer>
+</map>`)},
+	}
+	m, err := tmx.ParseFS(fsys, "map.tmx")
+	if err != nil {
+		t.Fatalf("Unable to parse map. Error was: %v", err)
+	}
+	// The tile is fitted into 8x16 pixels and centered in its cell
+	checkShapes(t, Layer(&m, &m.Layers[0], Options{}), []Shape{
+		{Kind: tmx.ShapeRectangle, X: 20, Y: 8, Width: 8, Height: 8, TileX: 1, TileY: 0},
+	})
+}
+
+func TestLayerGroupOffset(t *testing.T) {
+	fsys := fstest.MapFS{
+		"map.tmx": &fstest.MapFile{Data: []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<map version="1.2" orientation="orthogonal" width="2" height="1" tilewidth="16" tileheight="16">
+ <tileset firstgid="1" name="tiles" tilewidth="16" tileheight="16" tilecount="1" columns="1">
+  <image source="tiles.png" width="16" height="16"/>
+  <tile id="0">
+   <objectgroup draworder="index">
+    <object id="1" x="0" y="0" width="16" height="16"/>
+   </objectgroup>
+  </tile>
+ </tileset>
+ <group name="Offset" offsetx="100" offsety="50">
+  <layer name="Ground" width="2" height="1" offsetx="3">
+   <data encoding="csv">1,1</data>
+  </layer>
+ </group>
+</map>`)},
+	}
+	m, err := tmx.ParseFS(fsys, "map.tmx")
+	if err != nil {
+		t.Fatalf("Unable to parse map. Error was: %v", err)
+	}
+	l := &m.Groups[0].Layers[0]
+	// The shapes are where the layer is drawn, with its group's offset
+	checkShapes(t, Layer(&m, l, Options{}), []Shape{
+		{Kind: tmx.ShapeRectangle, X: 103, Y: 50, Width: 16, Height: 16, TileX: 0, TileY: 0},
+		{Kind: tmx.ShapeRectangle, X: 119, Y: 50, Width: 16, Height: 16, TileX: 1, TileY: 0},
+	})
+	checkShapes(t, Layer(&m, l, Options{Merge: true}), []Shape{
+		{Kind: tmx.ShapeRectangle, X: 103, Y: 50, Width: 32, Height: 16, TileX: 0, TileY: 0},
+	})
+}
@@ -0,0 +1,102 @@
+package tmx
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression is a format tile layer data can be compressed with
+type Compression struct {
+	// NewReader returns a reader that decompresses the data read from r
+	NewReader func(r io.Reader) (io.ReadCloser, error)
+	// NewWriter returns a writer that compresses the data written to w. It is
+	// nil if the format can only be read.
+	NewWriter func(w io.Writer) (io.WriteCloser, error)
+}
+
+var (
+	compressionsMu sync.RWMutex
+	compressions   = map[string]Compression{
+		"zlib": Compression{
+			NewReader: func(r io.Reader) (io.ReadCloser, error) {
+				return zlib.NewReader(r)
+			},
+			NewWriter: func(w io.Writer) (io.WriteCloser, error) {
+				return zlib.NewWriter(w), nil
+			},
+		},
+		"gzip": Compression{
+			NewReader: func(r io.Reader) (io.ReadCloser, error) {
+				return gzip.NewReader(r)
+			},
+			NewWriter: func(w io.Writer) (io.WriteCloser, error) {
+				return gzip.NewWriter(w), nil
+			},
+		},
+		"zstd": Compression{
+			NewReader: func(r io.Reader) (io.ReadCloser, error) {
+				// Layers are decoded one at a time, so the decoder doesn't need
+				// goroutines of its own. Closing the reader closes the decoder.
+				z, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+				if err != nil {
+					return nil, err
+				}
+				return z.IOReadCloser(), nil
+			},
+			NewWriter: func(w io.Writer) (io.WriteCloser, error) {
+				return zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+			},
+		},
+	}
+)
+
+// RegisterCompression makes a compression format available to decode and
+// encode tile layer data with. name is the value of the data element's
+// compression attribute. zlib, gzip and zstd are registered by default;
+// registering one of them again replaces it. c.NewWriter can be nil if the
+// format only needs to be read.
+func RegisterCompression(name string, c Compression) {
+	compressionsMu.Lock()
+	defer compressionsMu.Unlock()
+	compressions[name] = c
+}
+
+// newDecompressor returns a reader that decompresses r with the compression
+// format name. No compression is used if name is empty.
+func newDecompressor(r io.Reader, name string) (io.ReadCloser, error) {
+	if name == "" {
+		return io.NopCloser(r), nil
+	}
+	compressionsMu.RLock()
+	c, ok := compressions[name]
+	compressionsMu.RUnlock()
+	if !ok || c.NewReader == nil {
+		return nil, &ValidationError{Err: ErrUnknownCompression}
+	}
+	return c.NewReader(r)
+}
+
+// newCompressor returns a writer that compresses to w with the compression
+// format name. No compression is used if name is empty.
+func newCompressor(w io.Writer, name string) (io.WriteCloser, error) {
+	if name == "" {
+		return nopWriteCloser{w}, nil
+	}
+	compressionsMu.RLock()
+	c, ok := compressions[name]
+	compressionsMu.RUnlock()
+	if !ok || c.NewWriter == nil {
+		return nil, &ValidationError{Err: ErrUnknownCompression}
+	}
+	return c.NewWriter(w)
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
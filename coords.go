@@ -0,0 +1,190 @@
+package tmx
+
+import (
+	"image"
+	"math"
+)
+
+// The pixel coordinates used by TileToPixel and PixelToTile are those of the
+// map as Tiled draws it, with 0,0 at the top left of the map's bounding box.
+// Staggered and hexagonal maps are handled the way Tiled's renderers handle
+// them, so the math matches what designers see in the editor.
+
+// TileToPixel returns the pixel coordinates of the top left corner of the
+// bounding box of the tile at x, y. The bounding box is TileWidth by
+// TileHeight pixels for every orientation. Isometric maps are offset so the
+// left corner of the tile at 0, Height-1 is at x 0.
+func (m *Map) TileToPixel(x, y int) (float64, float64) {
+	switch m.Orientation {
+	case "isometric":
+		return float64((x-y+m.Height-1)*m.TileWidth) / 2, float64((x+y)*m.TileHeight) / 2
+	case "staggered", "hexagonal":
+		p := m.staggerParams()
+		if p.staggerX {
+			py := y * (p.tileHeight + p.sideLengthY)
+			if p.doStagger(x) {
+				py += p.rowHeight
+			}
+			return float64(x * p.columnWidth), float64(py)
+		}
+		px := x * (p.tileWidth + p.sideLengthX)
+		if p.doStagger(y) {
+			px += p.columnWidth
+		}
+		return float64(px), float64(y * p.rowHeight)
+	}
+	return float64(x * m.TileWidth), float64(y * m.TileHeight)
+}
+
+// PixelToTile returns the coordinates of the tile that covers the pixel
+// coordinates px, py. It is the inverse of TileToPixel, and the result may be
+// outside the map.
+func (m *Map) PixelToTile(px, py float64) (int, int) {
+	if m.TileWidth <= 0 || m.TileHeight <= 0 {
+		return 0, 0
+	}
+	switch m.Orientation {
+	case "isometric":
+		tx := (px - float64(m.Height*m.TileWidth)/2) / float64(m.TileWidth)
+		ty := py / float64(m.TileHeight)
+		return int(math.Floor(ty + tx)), int(math.Floor(ty - tx))
+	case "staggered", "hexagonal":
+		return m.staggeredPixelToTile(px, py)
+	}
+	return int(math.Floor(px / float64(m.TileWidth))), int(math.Floor(py / float64(m.TileHeight)))
+}
+
+// staggeredPixelToTile finds the tile of staggered and hexagonal maps with the
+// center nearest to px, py, out of the tiles around the row and column the
+// pixel is in. Staggered tiles are diamonds, so their distance is measured
+// relative to the size of the diamond, which finds the diamond that holds the
+// pixel. Hexagonal tiles use the straight line distance, like Tiled.
+func (m *Map) staggeredPixelToTile(px, py float64) (int, int) {
+	p := m.staggerParams()
+	var cx, cy int
+	if p.staggerX {
+		cx = int(math.Floor(px / float64(p.columnWidth)))
+		cy = int(math.Floor(py / float64(p.tileHeight+p.sideLengthY)))
+	} else {
+		cx = int(math.Floor(px / float64(p.tileWidth+p.sideLengthX)))
+		cy = int(math.Floor(py / float64(p.rowHeight)))
+	}
+	bx, by := cx, cy
+	best := math.Inf(1)
+	for y := cy - 1; y <= cy+1; y++ {
+		for x := cx - 1; x <= cx+1; x++ {
+			tx, ty := m.TileToPixel(x, y)
+			dx := px - tx - float64(p.tileWidth)/2
+			dy := py - ty - float64(p.tileHeight)/2
+			var d float64
+			if m.Orientation == "staggered" {
+				d = math.Abs(dx)/float64(p.tileWidth) + math.Abs(dy)/float64(p.tileHeight)
+			} else {
+				d = dx*dx + dy*dy
+			}
+			if d < best {
+				bx, by, best = x, y, d
+			}
+		}
+	}
+	return bx, by
+}
+
+// Neighbors returns the coordinates of the tiles that share an edge with the
+// tile at x, y, in clockwise order. Orthogonal and isometric tiles have 4
+// neighbours, in the order up, right, down and left of the tile's own
+// coordinates. Staggered tiles have the 4 diagonal neighbours, starting from
+// the top left if the y axis is staggered, or from the top right if the x axis
+// is staggered. Hexagonal tiles have 6 neighbours, starting from the top left
+// if the y axis is staggered, or from the top if the x axis is staggered.
+// Tiles outside the map are left out, unless the map is infinite.
+func (m *Map) Neighbors(x, y int) []image.Point {
+	var ns []image.Point
+	switch m.Orientation {
+	case "staggered", "hexagonal":
+		p := m.staggerParams()
+		hex := m.Orientation == "hexagonal"
+		if p.staggerX {
+			// Shifted columns are half a tile lower than their neighbours
+			s := 0
+			if p.doStagger(x) {
+				s = 1
+			}
+			if hex {
+				ns = append(ns, image.Pt(x, y-1))
+			}
+			ns = append(ns, image.Pt(x+1, y-1+s), image.Pt(x+1, y+s))
+			if hex {
+				ns = append(ns, image.Pt(x, y+1))
+			}
+			ns = append(ns, image.Pt(x-1, y+s), image.Pt(x-1, y-1+s))
+		} else {
+			// Shifted rows are half a tile to the right of their neighbours
+			s := 0
+			if p.doStagger(y) {
+				s = 1
+			}
+			ns = append(ns, image.Pt(x-1+s, y-1), image.Pt(x+s, y-1))
+			if hex {
+				ns = append(ns, image.Pt(x+1, y))
+			}
+			ns = append(ns, image.Pt(x+s, y+1), image.Pt(x-1+s, y+1))
+			if hex {
+				ns = append(ns, image.Pt(x-1, y))
+			}
+		}
+	default:
+		ns = []image.Point{
+			image.Pt(x, y-1),
+			image.Pt(x+1, y),
+			image.Pt(x, y+1),
+			image.Pt(x-1, y),
+		}
+	}
+	if m.Infinite != 0 {
+		return ns
+	}
+	bounds := image.Rect(0, 0, m.Width, m.Height)
+	in := ns[:0]
+	for _, n := range ns {
+		if n.In(bounds) {
+			in = append(in, n)
+		}
+	}
+	return in
+}
+
+// staggerParams are the measurements Tiled uses to lay out staggered and
+// hexagonal maps. Staggered maps are hexagonal maps with sides of length 0.
+type staggerParams struct {
+	tileWidth, tileHeight    int
+	staggerX, staggerEven    bool
+	sideLengthX, sideLengthY int
+	columnWidth, rowHeight   int
+}
+
+func (m *Map) staggerParams() staggerParams {
+	p := staggerParams{
+		// Tiled rounds hexagonal tiles down to an even size
+		tileWidth:   m.TileWidth &^ 1,
+		tileHeight:  m.TileHeight &^ 1,
+		staggerX:    m.StaggerAxis == "x",
+		staggerEven: m.StaggerIndex == "even",
+	}
+	if m.Orientation == "hexagonal" {
+		if p.staggerX {
+			p.sideLengthX = m.HexSideLength
+		} else {
+			p.sideLengthY = m.HexSideLength
+		}
+	}
+	p.columnWidth = (p.tileWidth-p.sideLengthX)/2 + p.sideLengthX
+	p.rowHeight = (p.tileHeight-p.sideLengthY)/2 + p.sideLengthY
+	return p
+}
+
+// doStagger returns whether the row or column i along the stagger axis is
+// shifted
+func (p staggerParams) doStagger(i int) bool {
+	return (i&1 != 0) != p.staggerEven
+}
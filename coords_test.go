@@ -0,0 +1,122 @@
+package tmx
+
+import (
+	"image"
+	"testing"
+)
+
+var coordMaps = map[string]Map{
+	"orthogonal": Map{Orientation: "orthogonal", Width: 4, Height: 4, TileWidth: 16, TileHeight: 16},
+	"isometric":  Map{Orientation: "isometric", Width: 4, Height: 4, TileWidth: 64, TileHeight: 32},
+	"staggered":  Map{Orientation: "staggered", Width: 4, Height: 4, TileWidth: 64, TileHeight: 32, StaggerAxis: "y", StaggerIndex: "odd"},
+	"staggeredX": Map{Orientation: "staggered", Width: 4, Height: 4, TileWidth: 64, TileHeight: 32, StaggerAxis: "x", StaggerIndex: "even"},
+	"hexagonal":  Map{Orientation: "hexagonal", Width: 4, Height: 4, TileWidth: 32, TileHeight: 28, HexSideLength: 14, StaggerAxis: "y", StaggerIndex: "odd"},
+	"hexagonalX": Map{Orientation: "hexagonal", Width: 4, Height: 4, TileWidth: 28, TileHeight: 32, HexSideLength: 14, StaggerAxis: "x", StaggerIndex: "odd"},
+}
+
+func TestTileToPixel(t *testing.T) {
+	exp := []struct {
+		m      string
+		x, y   int
+		px, py float64
+	}{
+		{"orthogonal", 2, 3, 32, 48},
+		{"isometric", 0, 0, 96, 0},
+		{"isometric", 1, 0, 128, 16},
+		{"isometric", 0, 3, 0, 48},
+		{"staggered", 0, 1, 32, 16},
+		{"staggered", 1, 2, 64, 32},
+		{"staggeredX", 0, 0, 0, 16},
+		{"staggeredX", 1, 1, 32, 32},
+		{"hexagonal", 0, 1, 16, 21},
+		{"hexagonal", 2, 2, 64, 42},
+		{"hexagonalX", 1, 0, 21, 16},
+		{"hexagonalX", 2, 1, 42, 32},
+	}
+	for _, e := range exp {
+		m := coordMaps[e.m]
+		px, py := m.TileToPixel(e.x, e.y)
+		if px != e.px || py != e.py {
+			t.Errorf("%v tile %v,%v was at the wrong pixel\nWanted: %v,%v\nGot: %v,%v", e.m, e.x, e.y, e.px, e.py, px, py)
+		}
+	}
+}
+
+func TestPixelToTileCenters(t *testing.T) {
+	for name, m := range coordMaps {
+		for y := -2; y < 6; y++ {
+			for x := -2; x < 6; x++ {
+				px, py := m.TileToPixel(x, y)
+				gx, gy := m.PixelToTile(px+float64(m.TileWidth)/2, py+float64(m.TileHeight)/2)
+				if gx != x || gy != y {
+					t.Errorf("%v center of tile %v,%v was in the wrong tile\nWanted: %v,%v\nGot: %v,%v", name, x, y, x, y, gx, gy)
+				}
+			}
+		}
+	}
+}
+
+func TestPixelToTileEdges(t *testing.T) {
+	exp := []struct {
+		m      string
+		px, py float64
+		x, y   int
+	}{
+		{"orthogonal", -1, -1, -1, -1},
+		{"orthogonal", 31.9, 16, 1, 1},
+		{"isometric", 97, 16, 0, 0},
+		{"isometric", 97, 1, -1, 0},
+		{"staggered", 2, 2, -1, -1},
+		{"staggered", 62, 30, 0, 1},
+		{"staggered", 32, 16, 0, 0},
+		{"hexagonal", 1, 1, -1, -1},
+		{"hexagonal", 16, 14, 0, 0},
+	}
+	for _, e := range exp {
+		m := coordMaps[e.m]
+		x, y := m.PixelToTile(e.px, e.py)
+		if x != e.x || y != e.y {
+			t.Errorf("%v pixel %v,%v was in the wrong tile\nWanted: %v,%v\nGot: %v,%v", e.m, e.px, e.py, e.x, e.y, x, y)
+		}
+	}
+}
+
+func TestNeighbors(t *testing.T) {
+	exp := []struct {
+		m    string
+		x, y int
+		ns   []image.Point
+	}{
+		{"orthogonal", 1, 1, []image.Point{{1, 0}, {2, 1}, {1, 2}, {0, 1}}},
+		{"orthogonal", 0, 0, []image.Point{{1, 0}, {0, 1}}},
+		{"isometric", 1, 1, []image.Point{{1, 0}, {2, 1}, {1, 2}, {0, 1}}},
+		{"staggered", 1, 1, []image.Point{{1, 0}, {2, 0}, {2, 2}, {1, 2}}},
+		{"staggered", 1, 2, []image.Point{{0, 1}, {1, 1}, {1, 3}, {0, 3}}},
+		{"staggeredX", 1, 1, []image.Point{{2, 0}, {2, 1}, {0, 1}, {0, 0}}},
+		{"hexagonal", 1, 2, []image.Point{{0, 1}, {1, 1}, {2, 2}, {1, 3}, {0, 3}, {0, 2}}},
+		{"hexagonal", 1, 1, []image.Point{{1, 0}, {2, 0}, {2, 1}, {2, 2}, {1, 2}, {0, 1}}},
+		{"hexagonalX", 1, 1, []image.Point{{1, 0}, {2, 1}, {2, 2}, {1, 2}, {0, 2}, {0, 1}}},
+	}
+	for _, e := range exp {
+		m := coordMaps[e.m]
+		ns := m.Neighbors(e.x, e.y)
+		if len(ns) != len(e.ns) {
+			t.Errorf("%v tile %v,%v has the wrong neighbours\nWanted: %v\nGot: %v", e.m, e.x, e.y, e.ns, ns)
+			continue
+		}
+		for i := range ns {
+			if ns[i] != e.ns[i] {
+				t.Errorf("%v tile %v,%v has the wrong neighbours\nWanted: %v\nGot: %v", e.m, e.x, e.y, e.ns, ns)
+				break
+			}
+		}
+	}
+}
+
+func TestNeighborsInfinite(t *testing.T) {
+	m := coordMaps["orthogonal"]
+	m.Infinite = 1
+	if ns := m.Neighbors(0, 0); len(ns) != 4 {
+		t.Errorf("Infinite map neighbours were limited to the map size\nWanted: %v\nGot: %v", 4, len(ns))
+	}
+}
@@ -2,13 +2,11 @@ package tmx
 
 import (
 	"bytes"
-	"compress/gzip"
-	"compress/zlib"
 	"encoding/base64"
 	"encoding/binary"
 	"encoding/csv"
 	"encoding/xml"
-	"errors"
+	"image"
 	"io"
 	"strconv"
 	"strings"
@@ -19,8 +17,8 @@ type Data struct {
 	// Encoding is the encoding used for the data. It can either be "base64"
 	// or "csv"
 	Encoding string `xml:"encoding,attr"`
-	// Compression is the compression used for the data. It can either be
-	// "gzip" or "zlib"
+	// Compression is the compression used for the data. It can be "gzip",
+	// "zlib", "zstd" or any format added with RegisterCompression
 	Compression string `xml:"compression,attr,omitempty"`
 	// Tiles are the tiles in the data. Not the same as TMXTiles from the Tileset.
 	Tiles []TileData `xml:"tile"`
@@ -28,6 +26,9 @@ type Data struct {
 	Chunks []Chunk `xml:"chunk"`
 	// Inner is the inner data
 	Inner string `xml:",innerxml"`
+
+	// line is where the data was found in the file
+	line int
 }
 
 // Chunk contains chunk data for a map. A chunk is a set of more than one
@@ -48,6 +49,29 @@ type Chunk struct {
 	Inner string `xml:",innerxml"`
 }
 
+// Bounds returns the area covered by the chunk, in tiles
+func (c *Chunk) Bounds() image.Rectangle {
+	return image.Rect(c.X, c.Y, c.X+c.Width, c.Y+c.Height)
+}
+
+// TileAt returns the tile at the tile coordinates x, y of the map. It returns
+// false if the coordinates are outside of the chunk.
+func (c *Chunk) TileAt(x, y int) (TileData, bool) {
+	if x < c.X || y < c.Y || x >= c.X+c.Width || y >= c.Y+c.Height {
+		return TileData{}, false
+	}
+	i := (y-c.Y)*c.Width + x - c.X
+	if i >= len(c.Tiles) {
+		return TileData{}, false
+	}
+	return c.Tiles[i], true
+}
+
+// path returns the path segment of the chunk used in errors
+func (c *Chunk) path() string {
+	return "chunk(" + strconv.Itoa(c.X) + "," + strconv.Itoa(c.Y) + ")"
+}
+
 // TileData contains the gid that maps a tile to the sprite
 type TileData struct {
 	// RawGID is the global tile ID given in the map
@@ -72,9 +96,12 @@ const (
 // UnmarshalXML implements the encoding/xml Unmarshaler interface
 func (da *Data) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 	type data Data
-	dat := data{}
+	dat := data{
+		line: currentLine(d),
+	}
+	line := dat.line
 	if err := d.DecodeElement(&dat, &start); err != nil {
-		return err
+		return errorAt(err, "data", line)
 	}
 	*da = (Data)(dat)
 	if len(da.Tiles) > 0 {
@@ -84,19 +111,87 @@ func (da *Data) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 	if len(da.Chunks) == 0 {
 		da.Tiles, err = decodeTileData(da.Inner, da.Encoding, da.Compression)
 		if err != nil {
-			return err
+			return errorAt(err, "data", line)
 		}
 	} else {
 		for i := range da.Chunks {
-			da.Chunks[i].Tiles, err = decodeTileData(da.Chunks[i].Inner, da.Encoding, da.Compression)
+			c := &da.Chunks[i]
+			c.Tiles, err = decodeTileData(c.Inner, da.Encoding, da.Compression)
 			if err != nil {
-				return err
+				return errorAt(errorAt(err, c.path(), line), "data", line)
 			}
 		}
 	}
 	return nil
 }
 
+// MarshalXML implements the encoding/xml Marshaler interface
+func (da Data) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return da.encode(e, start, 0)
+}
+
+// encode writes the data element. Tiles encoded as csv are broken into rows of
+// width tiles, or written on one line if width is 0.
+func (da Data) encode(e *xml.Encoder, start xml.StartElement, width int) error {
+	a := attrs{}
+	a.str("encoding", da.Encoding, "")
+	a.str("compression", da.Compression, "")
+	start, err := encodeStart(e, start, a)
+	if err != nil {
+		return err
+	}
+	if len(da.Chunks) == 0 {
+		if err = encodeTiles(e, da.Tiles, da.Encoding, da.Compression, width); err != nil {
+			return err
+		}
+	}
+	for _, c := range da.Chunks {
+		ca := attrs{}
+		ca.add("x", strconv.Itoa(c.X))
+		ca.add("y", strconv.Itoa(c.Y))
+		ca.add("width", strconv.Itoa(c.Width))
+		ca.add("height", strconv.Itoa(c.Height))
+		cs, err := encodeStart(e, startElement("chunk"), ca)
+		if err != nil {
+			return err
+		}
+		if err = encodeTiles(e, c.Tiles, da.Encoding, da.Compression, c.Width); err != nil {
+			return err
+		}
+		if err = e.EncodeToken(cs.End()); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// encodeTiles writes the tiles as the content of a data or chunk element
+func encodeTiles(e *xml.Encoder, tiles []TileData, encoding, compression string, width int) error {
+	if encoding == "" {
+		for _, t := range tiles {
+			a := attrs{}
+			a.uint("gid", t.raw(), 0)
+			if err := encodeEmpty(e, startElement("tile"), a); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	s, err := encodeTileData(tiles, encoding, compression, width)
+	if err != nil {
+		return err
+	}
+	return e.EncodeToken(xml.CharData(s))
+}
+
+// raw returns the global tile ID with the flipping bits set
+func (t TileData) raw() uint32 {
+	if t.RawGID != 0 {
+		return t.RawGID
+	}
+	return t.GID | t.Flipping
+}
+
 func decodeGID(u uint32) (uint32, uint32) {
 	h := u & HorizontalFlipFlag
 	v := u & VerticalFlipFlag
@@ -117,7 +212,7 @@ func decodeTileData(d, encoding, compression string) ([]TileData, error) {
 		cr.FieldsPerRecord = -1
 		recs, _ := cr.ReadAll()
 		if len(recs) < 1 {
-			return tiles, errors.New("No csv records found")
+			return tiles, ErrNoCSVRecords
 		}
 		for _, rec := range recs {
 			for i, id := range rec {
@@ -147,29 +242,14 @@ func decodeTileData(d, encoding, compression string) ([]TileData, error) {
 		}
 		breader = bytes.NewReader(buff)
 	} else {
-		return tiles, errors.New("Unknown Encoding")
+		return tiles, &ValidationError{Err: ErrUnknownEncoding}
 	}
 	// Setup decompression if needed
-	var zreader io.Reader
-	if compression == "" {
-		zreader = breader
-	} else if compression == "zlib" {
-		z, err := zlib.NewReader(breader)
-		if err != nil {
-			return tiles, err
-		}
-		defer z.Close()
-		zreader = z
-	} else if compression == "gzip" {
-		z, err := gzip.NewReader(breader)
-		if err != nil {
-			return tiles, err
-		}
-		defer z.Close()
-		zreader = z
-	} else {
-		return tiles, errors.New("Unknown Compression")
+	zreader, err := newDecompressor(breader, compression)
+	if err != nil {
+		return tiles, err
 	}
+	defer zreader.Close()
 	var nextInt uint32
 	for {
 		err := binary.Read(zreader, binary.LittleEndian, &nextInt)
@@ -188,3 +268,42 @@ func decodeTileData(d, encoding, compression string) ([]TileData, error) {
 	}
 	return tiles, nil
 }
+
+func encodeTileData(tiles []TileData, encoding, compression string, width int) (string, error) {
+	if encoding == "csv" {
+		var sb strings.Builder
+		sb.WriteString("\n")
+		for i, t := range tiles {
+			sb.WriteString(strconv.FormatUint(uint64(t.raw()), 10))
+			if i == len(tiles)-1 {
+				break
+			}
+			sb.WriteString(",")
+			if width > 0 && (i+1)%width == 0 {
+				sb.WriteString("\n")
+			}
+		}
+		sb.WriteString("\n")
+		return sb.String(), nil
+	}
+	if encoding != "base64" {
+		return "", &ValidationError{Err: ErrUnknownEncoding}
+	}
+	var buff bytes.Buffer
+	// Setup compression if needed
+	zwriter, err := newCompressor(&buff, compression)
+	if err != nil {
+		return "", err
+	}
+	b := make([]byte, 4*len(tiles))
+	for i, t := range tiles {
+		binary.LittleEndian.PutUint32(b[4*i:], t.raw())
+	}
+	if _, err := zwriter.Write(b); err != nil {
+		return "", err
+	}
+	if err := zwriter.Close(); err != nil {
+		return "", err
+	}
+	return "\n" + base64.StdEncoding.EncodeToString(buff.Bytes()) + "\n", nil
+}
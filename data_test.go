@@ -1,6 +1,8 @@
 package tmx
 
 import (
+	"errors"
+	"io"
 	"os"
 	"testing"
 )
@@ -107,8 +109,8 @@ func TestDataUnknownEncoding(t *testing.T) {
 	if err == nil {
 		t.Errorf("Able to parse %v data without proper encoding", TMXURL)
 	}
-	if err.Error() != "Unknown Encoding" {
-		t.Errorf("Error recieved trying to parse %v was incorrect. \n Wanted: %v\nGot: %v\n", TMXURL, "Unknown Encoding", err.Error())
+	if !errors.Is(err, ErrUnknownEncoding) {
+		t.Errorf("Error recieved trying to parse %v was incorrect. \n Wanted: %v\nGot: %v\n", TMXURL, ErrUnknownEncoding, err)
 	}
 }
 
@@ -159,8 +161,8 @@ func TestDataUnknownCompression(t *testing.T) {
 	if err == nil {
 		t.Errorf("Able to parse %v data without proper encoding", TMXURL)
 	}
-	if err.Error() != "Unknown Compression" {
-		t.Errorf("Error recieved trying to parse %v was incorrect. \n Wanted: %v\nGot: %v\n", TMXURL, "Unknown Encoding", err.Error())
+	if !errors.Is(err, ErrUnknownCompression) {
+		t.Errorf("Error recieved trying to parse %v was incorrect. \n Wanted: %v\nGot: %v\n", TMXURL, ErrUnknownCompression, err)
 	}
 }
 
@@ -236,6 +238,61 @@ func TestDataMalformedGZip(t *testing.T) {
 	}
 }
 
+func TestDataZstd(t *testing.T) {
+	TMXURL = "testData/zstdData.tmx"
+	f, err := os.Open(TMXURL)
+	if err != nil {
+		t.Errorf("Unable to open %v. Error was: %v", TMXURL, err)
+		return
+	}
+	defer f.Close()
+	m, err := Parse(f)
+	if err != nil {
+		t.Errorf("Unable to parse zstd compressed data")
+		return
+	}
+	for i, e := range testDataExpected {
+		if m.Layers[0].Data[0].Tiles[i].RawGID != e {
+			t.Errorf("Decoded Zstd data does not match GIDs\nWanted: %v\nGot: %v", e, m.Layers[0].Data[0].Tiles[i].RawGID)
+			return
+		}
+	}
+}
+
+func TestDataMalformedZstd(t *testing.T) {
+	TMXURL = "testData/malformedZstdData.tmx"
+	f, err := os.Open(TMXURL)
+	if err != nil {
+		t.Errorf("Unable to open %v. Error was: %v", TMXURL, err)
+		return
+	}
+	defer f.Close()
+	_, err = Parse(f)
+	if err == nil {
+		t.Errorf("Able to parse %v data with bad zstd compression", TMXURL)
+		return
+	}
+}
+
+func TestDataRegisterCompression(t *testing.T) {
+	RegisterCompression("none", Compression{
+		NewReader: func(r io.Reader) (io.ReadCloser, error) {
+			return io.NopCloser(r), nil
+		},
+	})
+	tiles, err := decodeTileData("AQAAAAIAAAA=", "base64", "none")
+	if err != nil {
+		t.Errorf("Unable to decode data with a registered compression. Error was: %v", err)
+		return
+	}
+	if len(tiles) != 2 || tiles[0].GID != 1 || tiles[1].GID != 2 {
+		t.Errorf("Decoded data with a registered compression does not match\nWanted: %v\nGot: %v", []uint32{1, 2}, tiles)
+	}
+	if _, err = encodeTileData(tiles, "base64", "none", 2); err == nil {
+		t.Errorf("Able to encode data with a compression that has no writer")
+	}
+}
+
 func TestDataFlipped(t *testing.T) {
 	TMXURL = "testData/flipData.tmx"
 	f, err := os.Open(TMXURL)
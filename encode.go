@@ -0,0 +1,122 @@
+package tmx
+
+import (
+	"encoding/xml"
+	"io"
+	"strconv"
+)
+
+// Encode writes the map to w as a TMX file that Tiled can open. Attributes
+// that hold Tiled's default value are left out, external tilesets are
+// written as references to their source rather than inlined, and objects made
+// from templates only write what they override, so parsing a map and
+// encoding it again keeps the file as close to the original as possible.
+func Encode(w io.Writer, m Map) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	e := xml.NewEncoder(w)
+	e.Indent("", " ")
+	if err := e.EncodeElement(m, startElement("map")); err != nil {
+		return err
+	}
+	if err := e.Flush(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// startElement returns a start element with the local name
+func startElement(name string) xml.StartElement {
+	return xml.StartElement{Name: xml.Name{Local: name}}
+}
+
+// attrs builds the attributes of an element. The methods that take a default
+// value leave the attribute out when the value is the default.
+type attrs []xml.Attr
+
+func (a *attrs) add(name, value string) {
+	*a = append(*a, xml.Attr{Name: xml.Name{Local: name}, Value: value})
+}
+
+func (a *attrs) str(name, value, def string) {
+	if value != def {
+		a.add(name, value)
+	}
+}
+
+func (a *attrs) int(name string, value, def int) {
+	if value != def {
+		a.add(name, strconv.Itoa(value))
+	}
+}
+
+func (a *attrs) uint(name string, value, def uint32) {
+	if value != def {
+		a.add(name, strconv.FormatUint(uint64(value), 10))
+	}
+}
+
+func (a *attrs) float(name string, value, def float64) {
+	if value != def {
+		a.add(name, formatFloat(value))
+	}
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// encodeStart writes the start element with the attributes
+func encodeStart(e *xml.Encoder, start xml.StartElement, a attrs) (xml.StartElement, error) {
+	start.Attr = a
+	return start, e.EncodeToken(start)
+}
+
+// encodeEmpty writes an element that only has attributes
+func encodeEmpty(e *xml.Encoder, start xml.StartElement, a attrs) error {
+	start, err := encodeStart(e, start, a)
+	if err != nil {
+		return err
+	}
+	return e.EncodeToken(start.End())
+}
+
+// encodeProperties writes the properties element, if there are any properties
+func encodeProperties(e *xml.Encoder, props []Property) error {
+	if len(props) == 0 {
+		return nil
+	}
+	start := startElement("properties")
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	for _, p := range props {
+		if err := e.EncodeElement(p, startElement("property")); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// encodeLayers writes the layers of a map or group
+func encodeLayers(e *xml.Encoder, nodes []LayerNode) error {
+	for _, n := range nodes {
+		var err error
+		switch l := n.(type) {
+		case *Layer:
+			err = e.EncodeElement(*l, startElement("layer"))
+		case *ObjectGroup:
+			err = e.EncodeElement(*l, startElement("objectgroup"))
+		case *ImageLayer:
+			err = e.EncodeElement(*l, startElement("imagelayer"))
+		case *Group:
+			err = e.EncodeElement(*l, startElement("group"))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
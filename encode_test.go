@@ -0,0 +1,140 @@
+package tmx
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func roundTrip(t *testing.T, name string) (Map, Map, string) {
+	m, err := ParseFS(os.DirFS("testData"), name)
+	if err != nil {
+		t.Fatalf("Unable to parse %v. Error was: %v", name, err)
+	}
+	var buf bytes.Buffer
+	if err = Encode(&buf, m); err != nil {
+		t.Fatalf("Unable to encode %v. Error was: %v", name, err)
+	}
+	m2, err := NewLoader(os.DirFS("testData")).Decode(bytes.NewReader(buf.Bytes()), name)
+	if err != nil {
+		t.Fatalf("Unable to parse encoded %v. Error was: %v\n%v", name, err, buf.String())
+	}
+	return m, m2, buf.String()
+}
+
+func TestEncodeTileData(t *testing.T) {
+	for _, name := range []string{"csvData.tmx", "tileData.tmx", "base64Data.tmx", "zlibData.tmx", "gzipData.tmx", "zstdData.tmx", "flipData.tmx"} {
+		m, m2, _ := roundTrip(t, name)
+		exp := m.Layers[0].Data[0].Tiles
+		got := m2.Layers[0].Data[0].Tiles
+		if len(got) != len(exp) {
+			t.Errorf("Encoded %v has the wrong number of tiles\nWanted: %v\nGot: %v", name, len(exp), len(got))
+			continue
+		}
+		for i := range exp {
+			if got[i] != exp[i] {
+				t.Errorf("Encoded %v does not match tile %v\nWanted: %v\nGot: %v", name, i, exp[i], got[i])
+				break
+			}
+		}
+	}
+}
+
+func TestEncodeChunks(t *testing.T) {
+	m, m2, _ := roundTrip(t, "chunkData.tmx")
+	exp := m.Layers[0].Data[0].Chunks[0]
+	got := m2.Layers[0].Data[0].Chunks[0]
+	if got.X != exp.X || got.Y != exp.Y || got.Width != exp.Width || got.Height != exp.Height {
+		t.Errorf("Encoded chunk bounds do not match\nWanted: %v,%v %vx%v\nGot: %v,%v %vx%v", exp.X, exp.Y, exp.Width, exp.Height, got.X, got.Y, got.Width, got.Height)
+	}
+	for i := range exp.Tiles {
+		if got.Tiles[i] != exp.Tiles[i] {
+			t.Errorf("Encoded chunk does not match tile %v\nWanted: %v\nGot: %v", i, exp.Tiles[i], got.Tiles[i])
+			return
+		}
+	}
+}
+
+func TestEncodeExternalTileset(t *testing.T) {
+	m, m2, out := roundTrip(t, "tilesheetTest.tmx")
+	if !strings.Contains(out, `<tileset firstgid="469" source="external.tsx">`) {
+		t.Errorf("External tileset was not written as a reference\n%v", out)
+	}
+	if m2.Tilesets[1].Name != m.Tilesets[1].Name {
+		t.Errorf("External tileset not loaded after encoding\nWanted: %v\nGot: %v", m.Tilesets[1].Name, m2.Tilesets[1].Name)
+	}
+}
+
+func TestEncodeDefaults(t *testing.T) {
+	_, m2, out := roundTrip(t, "objects.tmx")
+	for _, def := range []string{`opacity="1"`, `visible="1"`, `draworder="topdown"`, `kerning="1"`} {
+		if strings.Contains(out, def) {
+			t.Errorf("Default value %v was written\n%v", def, out)
+		}
+	}
+	if m2.Groups[0].ImageLayers[0].OffsetX != 5 {
+		t.Errorf("Image layer offset was not encoded\nWanted: %v\nGot: %v", 5, m2.Groups[0].ImageLayers[0].OffsetX)
+	}
+	if len(m2.ObjectGroups[0].Objects[1].Ellipses) != 1 {
+		t.Errorf("Object ellipse was not encoded")
+	}
+}
+
+func TestEncodeTemplateObjects(t *testing.T) {
+	m, m2, out := roundTrip(t, "objects.tmx")
+	// Objects keep referring to their template instead of inlining it
+	for _, s := range []string{`<object id="3" template="Wheel.tx" x="26" y="5"></object>`, `<object id="7" template="Wheel.tx" name="Wheel2" x="0" y="0"></object>`} {
+		if !strings.Contains(out, s) {
+			t.Errorf("Template object was not written as a reference\nWanted: %v\nGot: %v", s, out)
+		}
+	}
+	for i, o := range m.ObjectGroups[0].Objects {
+		o2 := m2.ObjectGroups[0].Objects[i]
+		if o2.Name != o.Name || o2.X != o.X || o2.Width != o.Width || len(o2.Ellipses) != len(o.Ellipses) {
+			t.Errorf("Encoded object %v does not match\nWanted: %+v\nGot: %+v", o.ID, o, o2)
+		}
+	}
+}
+
+func TestEncodeProperties(t *testing.T) {
+	m, m2, _ := roundTrip(t, "properties.tmx")
+	exp := m.ObjectGroups[0].Objects[0].Properties
+	got := m2.ObjectGroups[0].Objects[0].Properties
+	for i := range exp {
+		if got[i].Name != exp[i].Name || got[i].Type != exp[i].Type || got[i].Value != exp[i].Value {
+			t.Errorf("Encoded property does not match\nWanted: %v\nGot: %v", exp[i], got[i])
+		}
+	}
+}
+
+func TestEncodeText(t *testing.T) {
+	m, m2, _ := roundTrip(t, "text.tmx")
+	exp := m.ObjectGroups[0].Objects[0].Text[0]
+	got := m2.ObjectGroups[0].Objects[0].Text[0]
+	if got != exp {
+		t.Errorf("Encoded text does not match\nWanted: %v\nGot: %v", exp, got)
+	}
+}
+
+func TestEncodeUnknownCompression(t *testing.T) {
+	m := Map{
+		Layers: []Layer{
+			Layer{
+				Width:  1,
+				Height: 1,
+				Data: []Data{
+					Data{
+						Encoding:    "base64",
+						Compression: "unknown",
+						Tiles:       []TileData{TileData{GID: 1}},
+					},
+				},
+			},
+		},
+	}
+	var buf bytes.Buffer
+	if err := Encode(&buf, m); err == nil {
+		t.Errorf("Able to encode data with an unknown compression")
+	}
+}
@@ -0,0 +1,213 @@
+package tmx
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"strconv"
+)
+
+var (
+	// ErrUnknownEncoding is the reason given when tile layer data uses an
+	// encoding other than csv or base64
+	ErrUnknownEncoding = errors.New("Unknown Encoding")
+	// ErrUnknownCompression is the reason given when tile layer data uses a
+	// compression that hasn't been registered with RegisterCompression
+	ErrUnknownCompression = errors.New("Unknown Compression")
+	// ErrNoCSVRecords is the reason given when csv tile layer data is empty
+	ErrNoCSVRecords = errors.New("No csv records found")
+	// ErrUnknownLayerType is the reason given when a layer in Tiled's JSON
+	// format has a type other than tilelayer, objectgroup, imagelayer or group
+	ErrUnknownLayerType = errors.New("Unknown Layer Type")
+	// ErrTileCount is the reason given when tile layer data doesn't hold one
+	// tile for every cell of its layer or chunk
+	ErrTileCount = errors.New("Wrong Tile Count")
+	// ErrGIDRange is the reason given when a tile refers to a global tile ID
+	// outside the range of every one of the map's tilesets
+	ErrGIDRange = errors.New("GID Out Of Range")
+	// ErrInvalidWangID is the reason given when a wang tile's wang ID is
+	// neither 8 comma separated color indexes nor a 32-bit hexadecimal number
+	ErrInvalidWangID = errors.New("Invalid Wang ID")
+	// ErrEmptyTemplate is the reason given when a template has no object
+	ErrEmptyTemplate = errors.New("Empty Template")
+	// ErrUnreachableSource is the reason given when a template's tileset
+	// can't be referred to by a source relative to the map using the template
+	ErrUnreachableSource = errors.New("Unreachable Source")
+)
+
+// DecodeError is the error returned when an element of a map, tileset or
+// template is malformed, such as tile layer data that isn't valid base64 or an
+// attribute that isn't a number.
+type DecodeError struct {
+	// File is the file the element is in, if it is known
+	File string
+	// Path is the path of the element from the root of the file, such as
+	// "map/group[Group 1]/layer[Ground]/data/chunk(-32,-16)". Layers, groups
+	// and tilesets are named by their name, and objects and tiles by their ID.
+	Path string
+	// Line is the line of the element in the file, or 0 if it is unknown
+	Line int
+	// Err is the reason the element couldn't be decoded
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return "tmx: " + location(e.File, e.Line, e.Path) + e.Err.Error()
+}
+
+// Unwrap returns the reason the element couldn't be decoded
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// ExternalRefError is the error returned when an external tileset or template
+// can't be loaded. If the referenced file is malformed, Err holds the
+// *DecodeError or *ValidationError from that file.
+type ExternalRefError struct {
+	// File is the file that references the source
+	File string
+	// Path is the path of the referencing element, such as "map/tileset[1]"
+	// or "map/objectgroup[Enemies]/object[7]"
+	Path string
+	// Line is the line of the referencing element, or 0 if it is unknown
+	Line int
+	// Source is the location of the referenced file within the file system
+	Source string
+	// Err is the reason the source couldn't be loaded
+	Err error
+}
+
+func (e *ExternalRefError) Error() string {
+	return "tmx: " + location(e.File, e.Line, e.Path) + "loading " + strconv.Quote(e.Source) + ": " + e.Err.Error()
+}
+
+// Unwrap returns the reason the source couldn't be loaded
+func (e *ExternalRefError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationError is the error returned when an element is well formed but
+// holds a value this package can't use, such as tile layer data with an
+// unknown compression.
+type ValidationError struct {
+	// File is the file the element is in, if it is known
+	File string
+	// Path is the path of the element from the root of the file. See
+	// DecodeError.Path.
+	Path string
+	// Line is the line of the element in the file, or 0 if it is unknown
+	Line int
+	// Err is the reason the element is invalid
+	Err error
+}
+
+func (e *ValidationError) Error() string {
+	return "tmx: " + location(e.File, e.Line, e.Path) + e.Err.Error()
+}
+
+// Unwrap returns the reason the element is invalid
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// location formats the position of an element for an error message
+func location(file string, line int, path string) string {
+	s := file
+	if line > 0 {
+		s += ":" + strconv.Itoa(line)
+	}
+	if s != "" {
+		s += ": "
+	}
+	if path != "" {
+		s += path + ": "
+	}
+	return s
+}
+
+// elementPath returns the path segment of an element, with its key in
+// brackets if it has one
+func elementPath(name, key string) string {
+	if key == "" {
+		return name
+	}
+	return name + "[" + key + "]"
+}
+
+// joinPath prepends the path segment seg to path
+func joinPath(seg, path string) string {
+	if path == "" {
+		return seg
+	}
+	return seg + "/" + path
+}
+
+// errorAt records that err happened inside the element seg, which starts at
+// line. Errors already returned by a child element have seg prepended to their
+// path and keep the child's line if they have one, and any other error becomes
+// a *DecodeError.
+func errorAt(err error, seg string, line int) error {
+	switch e := err.(type) {
+	case nil:
+		return nil
+	case *DecodeError:
+		e.Path = joinPath(seg, e.Path)
+		if e.Line == 0 {
+			e.Line = line
+		}
+	case *ValidationError:
+		e.Path = joinPath(seg, e.Path)
+		if e.Line == 0 {
+			e.Line = line
+		}
+	case *ExternalRefError:
+		e.Path = joinPath(seg, e.Path)
+		if e.Line == 0 {
+			e.Line = line
+		}
+	case *xml.SyntaxError:
+		return &DecodeError{Path: seg, Line: e.Line, Err: err}
+	default:
+		return &DecodeError{Path: seg, Line: line, Err: err}
+	}
+	return err
+}
+
+// errorIn records that err happened in the file name. data is the content of
+// the file, used to find the line of JSON syntax errors.
+func errorIn(err error, name string, data []byte) error {
+	switch e := err.(type) {
+	case nil:
+		return nil
+	case *DecodeError:
+		if e.File == "" {
+			e.File = name
+		}
+	case *ValidationError:
+		if e.File == "" {
+			e.File = name
+		}
+	case *ExternalRefError:
+		if e.File == "" {
+			e.File = name
+		}
+	case *xml.SyntaxError:
+		return &DecodeError{File: name, Line: e.Line, Err: err}
+	case *json.SyntaxError:
+		line := 0
+		if e.Offset <= int64(len(data)) {
+			line = bytes.Count(data[:e.Offset], []byte("\n")) + 1
+		}
+		return &DecodeError{File: name, Line: line, Err: err}
+	default:
+		return &DecodeError{File: name, Err: err}
+	}
+	return err
+}
+
+// currentLine returns the line the decoder has reached
+func currentLine(d *xml.Decoder) int {
+	line, _ := d.InputPos()
+	return line
+}
@@ -0,0 +1,85 @@
+package tmx
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"testing"
+)
+
+func TestErrorChunkPath(t *testing.T) {
+	_, err := ParseFS(os.DirFS("testData"), "malformedChunkData.tmx")
+	var de *DecodeError
+	if !errors.As(err, &de) {
+		t.Fatalf("Error parsing malformed chunk was not a DecodeError\nGot: %v", err)
+	}
+	if de.Path != "map/layer[Tile Layer 1]/data/chunk(-32,-16)" {
+		t.Errorf("DecodeError path was incorrect\nWanted: %v\nGot: %v", "map/layer[Tile Layer 1]/data/chunk(-32,-16)", de.Path)
+	}
+	if de.File != "malformedChunkData.tmx" {
+		t.Errorf("DecodeError file was incorrect\nWanted: %v\nGot: %v", "malformedChunkData.tmx", de.File)
+	}
+	if de.Line != 7 {
+		t.Errorf("DecodeError line was incorrect\nWanted: %v\nGot: %v", 7, de.Line)
+	}
+}
+
+func TestErrorValidation(t *testing.T) {
+	_, err := ParseFS(os.DirFS("testData"), "unknownCompressionData.tmx")
+	var ve *ValidationError
+	if !errors.As(err, &ve) {
+		t.Fatalf("Error parsing unknown compression was not a ValidationError\nGot: %v", err)
+	}
+	if ve.Path != "map/layer[Tile Layer 1]/data" {
+		t.Errorf("ValidationError path was incorrect\nWanted: %v\nGot: %v", "map/layer[Tile Layer 1]/data", ve.Path)
+	}
+	if !errors.Is(err, ErrUnknownCompression) {
+		t.Errorf("ValidationError does not wrap ErrUnknownCompression\nGot: %v", err)
+	}
+}
+
+func TestErrorExternalTileset(t *testing.T) {
+	_, err := ParseFS(os.DirFS("testData"), "tsxNotExist.tmx")
+	var re *ExternalRefError
+	if !errors.As(err, &re) {
+		t.Fatalf("Error parsing missing tileset was not an ExternalRefError\nGot: %v", err)
+	}
+	if re.Source != "doesNotExist.tsx" {
+		t.Errorf("ExternalRefError source was incorrect\nWanted: %v\nGot: %v", "doesNotExist.tsx", re.Source)
+	}
+	if re.File != "tsxNotExist.tmx" || re.Line != 3 {
+		t.Errorf("ExternalRefError position was incorrect\nWanted: %v:%v\nGot: %v:%v", "tsxNotExist.tmx", 3, re.File, re.Line)
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("ExternalRefError does not wrap fs.ErrNotExist\nGot: %v", err)
+	}
+}
+
+func TestErrorExternalTemplate(t *testing.T) {
+	_, err := ParseFS(os.DirFS("testData"), "malformedObjectTemplate.tmx")
+	var re *ExternalRefError
+	if !errors.As(err, &re) {
+		t.Fatalf("Error parsing malformed template was not an ExternalRefError\nGot: %v", err)
+	}
+	if re.Path != "map/objectgroup[Object Layer 1]/object[7]" {
+		t.Errorf("ExternalRefError path was incorrect\nWanted: %v\nGot: %v", "map/objectgroup[Object Layer 1]/object[7]", re.Path)
+	}
+	var de *DecodeError
+	if !errors.As(err, &de) {
+		t.Fatalf("ExternalRefError does not wrap a DecodeError\nGot: %v", err)
+	}
+	if de.File != "malformedObjectTemplate.tx" || de.Path != "template/object" {
+		t.Errorf("Template DecodeError position was incorrect\nWanted: %v %v\nGot: %v %v", "malformedObjectTemplate.tx", "template/object", de.File, de.Path)
+	}
+}
+
+func TestErrorJSON(t *testing.T) {
+	_, err := ParseFS(os.DirFS("testData"), "malformedJSON.tmj")
+	var de *DecodeError
+	if !errors.As(err, &de) {
+		t.Fatalf("Error parsing malformed JSON was not a DecodeError\nGot: %v", err)
+	}
+	if de.Path != "map/layer[Tile Layer 1]/data" {
+		t.Errorf("DecodeError path was incorrect\nWanted: %v\nGot: %v", "map/layer[Tile Layer 1]/data", de.Path)
+	}
+}
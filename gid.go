@@ -0,0 +1,307 @@
+package tmx
+
+import (
+	"image"
+	"math"
+	"sort"
+)
+
+// TileInfo is everything a global tile ID refers to
+type TileInfo struct {
+	// Tileset is the tileset the tile belongs to
+	Tileset *Tileset
+	// ID is the local tile ID within the tileset
+	ID uint32
+	// Tile is the metadata of the tile, such as properties, animation and
+	// collision shapes. It is nil if the tileset has no metadata for the tile.
+	Tile *Tile
+	// Image is the image the tile is cut from: the tileset image, or the
+	// tile's own image in image collection tilesets. It is nil if the tile
+	// has no image.
+	Image *Image
+	// Rect is the source rectangle of the tile in Image. It is empty if the
+	// tile is a whole image whose size isn't in the file, in which case the
+	// image's own bounds are the tile.
+	Rect image.Rectangle
+}
+
+// TileForGID returns the tileset, local tile ID, metadata and source rectangle
+// of the tile with the global tile ID gid. Any flipping flags in gid are
+// ignored. It returns false if gid is 0 or isn't in the range of any tileset.
+//
+// Maps read by a Loader have a lookup table of their tilesets, built by
+// IndexTilesets. Maps without one, such as maps built in code, are searched
+// tile by tile instead. TileForGID never modifies the map, so it's safe to call
+// from many goroutines.
+func (m *Map) TileForGID(gid uint32) (TileInfo, bool) {
+	gid, _ = decodeGID(gid)
+	if m.gids != nil {
+		return m.gids.lookup(gid)
+	}
+	return lookupGID(m.Tilesets, gid)
+}
+
+// IndexTilesets builds the lookup table TileForGID uses to find tiles quickly.
+// Maps read by a Loader are indexed already. Call it after building a map in
+// code, or after changing the Tilesets of a map, since the table doesn't see
+// changes made after it's built. It must not be called while other goroutines
+// are using the map.
+func (m *Map) IndexTilesets() {
+	m.gids = newGIDTable(m.Tilesets)
+}
+
+// TilesetKind is the way the tiles of a tileset get their images
+type TilesetKind int
+
+const (
+	// TilesetImage is a tileset with one image, cut into a grid of tiles
+	TilesetImage TilesetKind = iota
+	// TilesetCollection is a tileset without an image of its own, where each
+	// tile has its own image, or a sub-rectangle of one
+	TilesetCollection
+)
+
+// Kind returns whether the tileset is cut from one image or is a collection
+// of images
+func (t *Tileset) Kind() TilesetKind {
+	if len(t.Image) > 0 {
+		return TilesetImage
+	}
+	return TilesetCollection
+}
+
+// TileImage returns the image the tile with the local tile ID is cut from, and
+// the source rectangle of the tile in it. Tiles of tilesets cut from one image
+// use TileRect. Tiles of image collections use their own image, and the
+// sub-rectangle given by their X, Y, Width and Height, which is the whole
+// image by default. It returns false if the tile has no image, or if the
+// rectangle isn't known because the tile has no Width or Height and its
+// image's size isn't in the file. The image is still returned in that case,
+// with an empty rectangle, so it can be loaded to find its size.
+func (t *Tileset) TileImage(id uint32) (*Image, image.Rectangle, bool) {
+	var tile *Tile
+	for i := range t.Tiles {
+		if t.Tiles[i].ID == id {
+			tile = &t.Tiles[i]
+			break
+		}
+	}
+	return t.tileImage(id, tile)
+}
+
+// tileImage is TileImage for the tile with the local tile ID and metadata
+// tile, which may be nil
+func (t *Tileset) tileImage(id uint32, tile *Tile) (*Image, image.Rectangle, bool) {
+	if t.Kind() == TilesetImage {
+		return &t.Image[0], t.TileRect(id), true
+	}
+	if tile == nil || len(tile.Image) == 0 {
+		return nil, image.Rectangle{}, false
+	}
+	img := &tile.Image[0]
+	w, h := tile.Width, tile.Height
+	if w == 0 {
+		w = int(img.Width) - tile.X
+	}
+	if h == 0 {
+		h = int(img.Height) - tile.Y
+	}
+	if w <= 0 || h <= 0 {
+		// The image's size isn't in the file, so the rectangle isn't known
+		return img, image.Rectangle{}, false
+	}
+	return img, image.Rect(tile.X, tile.Y, tile.X+w, tile.Y+h), true
+}
+
+// tileRange returns how many local tile IDs the tileset uses. Tiles removed
+// from image collections leave gaps in their IDs, so the range can be more
+// than TileCount.
+func (t *Tileset) tileRange() uint32 {
+	n := uint32(0)
+	if t.TileCount > 0 {
+		n = uint32(t.TileCount)
+	}
+	if t.Kind() == TilesetCollection {
+		for _, tile := range t.Tiles {
+			if tile.ID >= n {
+				n = tile.ID + 1
+			}
+		}
+	}
+	return n
+}
+
+// RenderSize returns the size in pixels a tile with the source rectangle r is
+// drawn at, in a map with tiles tileWidth by tileHeight pixels. Tilesets with
+// a TileRenderSize of grid draw their tiles at the map's tile size, and with
+// a FillMode of preserve-aspect-fit they are scaled to fit inside it, keeping
+// their aspect ratio. Tiled centers such tiles in the area of the grid size.
+func (t *Tileset) RenderSize(r image.Rectangle, tileWidth, tileHeight int) (float64, float64) {
+	w, h := float64(r.Dx()), float64(r.Dy())
+	if t.TileRenderSize != "grid" {
+		return w, h
+	}
+	gw, gh := float64(tileWidth), float64(tileHeight)
+	if t.FillMode != "preserve-aspect-fit" || w <= 0 || h <= 0 {
+		return gw, gh
+	}
+	scale := math.Min(gw/w, gh/h)
+	return w * scale, h * scale
+}
+
+// Alignment returns the ObjectAlignment of the tileset, with unspecified
+// replaced by Tiled's default for maps with the orientation: bottom for
+// isometric maps and bottomleft for the others
+func (t *Tileset) Alignment(orientation string) string {
+	if t.ObjectAlignment != "" && t.ObjectAlignment != "unspecified" {
+		return t.ObjectAlignment
+	}
+	if orientation == "isometric" {
+		return "bottom"
+	}
+	return "bottomleft"
+}
+
+// AlignmentOffset returns the offset from the alignment point of a tile object
+// w by h pixels, which is its position, to its top left corner
+func AlignmentOffset(alignment string, w, h float64) (float64, float64) {
+	var x, y float64
+	switch alignment {
+	case "top", "center", "bottom":
+		x = -w / 2
+	case "topright", "right", "bottomright":
+		x = -w
+	}
+	switch alignment {
+	case "left", "center", "right":
+		y = -h / 2
+	case "bottomleft", "bottom", "bottomright":
+		y = -h
+	}
+	return x, y
+}
+
+// TileObjectBounds returns the top left and size of the tile object o as it is
+// drawn, in the same coordinates as its position. The tile is drawn at the
+// object's Width and Height, or its image size if they're 0, and placed by
+// the Alignment of its tileset. It returns false if o isn't a tile object, its
+// GID isn't in the map's tilesets, or it has no size and neither does its
+// image.
+func (m *Map) TileObjectBounds(o *Object) (x, y, w, h float64, ok bool) {
+	if o.GID == 0 {
+		return 0, 0, 0, 0, false
+	}
+	info, ok := m.TileForGID(o.GID)
+	if !ok {
+		return 0, 0, 0, 0, false
+	}
+	w, h = o.Width, o.Height
+	if w == 0 || h == 0 {
+		if info.Rect.Empty() {
+			return 0, 0, 0, 0, false
+		}
+		w, h = float64(info.Rect.Dx()), float64(info.Rect.Dy())
+	}
+	dx, dy := AlignmentOffset(info.Tileset.Alignment(m.Orientation), w, h)
+	return o.X + dx, o.Y + dy, w, h, true
+}
+
+// TileRect returns the source rectangle of the tile with the local tile ID in
+// the tileset image, taking into account the tileset's margin and spacing.
+// Tiles of image collections have their own images, so use TileImage for
+// them.
+func (t *Tileset) TileRect(id uint32) image.Rectangle {
+	columns := t.Columns
+	if columns == 0 && len(t.Image) > 0 && t.TileWidth+t.Spacing > 0 {
+		columns = (int(t.Image[0].Width) - 2*int(t.Margin) + t.Spacing) / (t.TileWidth + t.Spacing)
+	}
+	if columns <= 0 {
+		columns = 1
+	}
+	x := int(t.Margin) + int(id)%columns*(t.TileWidth+t.Spacing)
+	y := int(t.Margin) + int(id)/columns*(t.TileHeight+t.Spacing)
+	return image.Rect(x, y, x+t.TileWidth, y+t.TileHeight)
+}
+
+// gidTable finds the tileset and tile metadata of global tile IDs
+type gidTable struct {
+	// tilesets are sorted by FirstGID
+	tilesets []*Tileset
+	// tiles are the tiles with metadata in each of the tilesets, by local ID
+	tiles []map[uint32]*Tile
+}
+
+func newGIDTable(tilesets []Tileset) *gidTable {
+	t := &gidTable{
+		tilesets: make([]*Tileset, len(tilesets)),
+	}
+	for i := range tilesets {
+		t.tilesets[i] = &tilesets[i]
+	}
+	sort.SliceStable(t.tilesets, func(i, j int) bool {
+		return t.tilesets[i].FirstGID < t.tilesets[j].FirstGID
+	})
+	t.tiles = make([]map[uint32]*Tile, len(t.tilesets))
+	for i, ts := range t.tilesets {
+		t.tiles[i] = make(map[uint32]*Tile, len(ts.Tiles))
+		for j := range ts.Tiles {
+			t.tiles[i][ts.Tiles[j].ID] = &ts.Tiles[j]
+		}
+	}
+	return t
+}
+
+func (t *gidTable) lookup(gid uint32) (TileInfo, bool) {
+	if gid == 0 {
+		return TileInfo{}, false
+	}
+	i := sort.Search(len(t.tilesets), func(i int) bool {
+		return t.tilesets[i].FirstGID > gid
+	}) - 1
+	if i < 0 {
+		return TileInfo{}, false
+	}
+	ts := t.tilesets[i]
+	id := gid - ts.FirstGID
+	return tileInfo(ts, id, t.tiles[i][id])
+}
+
+// lookupGID finds the tile with the global tile ID gid by searching the
+// tilesets, for maps without a lookup table
+func lookupGID(tilesets []Tileset, gid uint32) (TileInfo, bool) {
+	var ts *Tileset
+	for i := range tilesets {
+		t := &tilesets[i]
+		if t.FirstGID <= gid && (ts == nil || t.FirstGID > ts.FirstGID) {
+			ts = t
+		}
+	}
+	if gid == 0 || ts == nil {
+		return TileInfo{}, false
+	}
+	id := gid - ts.FirstGID
+	var tile *Tile
+	for i := range ts.Tiles {
+		if ts.Tiles[i].ID == id {
+			tile = &ts.Tiles[i]
+			break
+		}
+	}
+	return tileInfo(ts, id, tile)
+}
+
+// tileInfo returns the TileInfo of the tile with the local tile ID and
+// metadata tile, which may be nil. It returns false if id is past the end of
+// the tileset.
+func tileInfo(ts *Tileset, id uint32, tile *Tile) (TileInfo, bool) {
+	if n := ts.tileRange(); n > 0 && id >= n {
+		return TileInfo{}, false
+	}
+	info := TileInfo{
+		Tileset: ts,
+		ID:      id,
+		Tile:    tile,
+	}
+	info.Image, info.Rect, _ = ts.tileImage(id, tile)
+	return info, true
+}
@@ -0,0 +1,277 @@
+package tmx
+
+import (
+	"image"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestTileForGID(t *testing.T) {
+	m, err := ParseFS(os.DirFS("testData"), "tilesheetTest.tmx")
+	if err != nil {
+		t.Errorf("Unable to parse tilesheetTest.tmx. Error was: %v", err)
+		return
+	}
+	tests := []struct {
+		gid     uint32
+		tileset string
+		id      uint32
+		rect    image.Rectangle
+	}{
+		{1, "embedded", 0, image.Rect(0, 0, 16, 16)},
+		{28, "embedded", 27, image.Rect(17, 17, 33, 33)},
+		{468, "embedded", 467, image.Rect(425, 289, 441, 305)},
+		{469, "external", 0, image.Rect(0, 0, 16, 16)},
+		{470 | HorizontalFlipFlag, "external", 1, image.Rect(17, 0, 33, 16)},
+	}
+	for _, test := range tests {
+		info, ok := m.TileForGID(test.gid)
+		if !ok {
+			t.Errorf("GID %v was not found", test.gid)
+			continue
+		}
+		if info.Tileset.Name != test.tileset || info.ID != test.id || info.Rect != test.rect {
+			t.Errorf("GID %v not properly resolved\nWanted: %v %v %v\nGot: %v %v %v", test.gid, test.tileset, test.id, test.rect, info.Tileset.Name, info.ID, info.Rect)
+		}
+	}
+	for _, gid := range []uint32{0, 469 + 48} {
+		if _, ok := m.TileForGID(gid); ok {
+			t.Errorf("GID %v was found when it is out of range", gid)
+		}
+	}
+}
+
+func TestTileForGIDMetadata(t *testing.T) {
+	m, err := ParseFS(os.DirFS("testData"), "jsonMap.tmj")
+	if err != nil {
+		t.Errorf("Unable to parse jsonMap.tmj. Error was: %v", err)
+		return
+	}
+	info, ok := m.TileForGID(1)
+	if !ok || info.Tile == nil {
+		t.Errorf("Tile metadata not found for GID 1")
+		return
+	}
+	if info.Tile.Properties[0].Name != "walkable" {
+		t.Errorf("Wrong tile metadata for GID 1\nWanted: %v\nGot: %v", "walkable", info.Tile.Properties[0].Name)
+	}
+	info, ok = m.TileForGID(2)
+	if !ok || info.Tile != nil {
+		t.Errorf("Tile metadata found for GID 2 when there is none")
+	}
+}
+
+func TestTileForGIDHandBuilt(t *testing.T) {
+	m := Map{
+		Tilesets: []Tileset{
+			Tileset{FirstGID: 11, Name: "second", TileWidth: 8, TileHeight: 8, Margin: 2, Spacing: 2, Image: []Image{Image{Width: 42, Height: 42}}},
+			Tileset{FirstGID: 1, Name: "first", TileWidth: 8, TileHeight: 8, TileCount: 10},
+		},
+	}
+	info, ok := m.TileForGID(10)
+	if !ok || info.Tileset.Name != "first" {
+		t.Errorf("GID 10 not found in the first tileset")
+	}
+	info, ok = m.TileForGID(15)
+	if !ok || info.Tileset.Name != "second" || info.Rect != image.Rect(2, 12, 10, 20) {
+		t.Errorf("GID 15 not properly resolved. Got: %v %v", info.Tileset.Name, info.Rect)
+	}
+}
+
+func TestTileImageCollection(t *testing.T) {
+	m, err := ParseFS(os.DirFS("testData"), "imageCollection.tmx")
+	if err != nil {
+		t.Fatalf("Unable to parse imageCollection.tmx. Error was: %v", err)
+	}
+	checkImageCollection(t, m)
+}
+
+func TestTileImageCollectionJSON(t *testing.T) {
+	m, err := ParseJSON(strings.NewReader(`{"orientation":"orthogonal","width":2,"height":1,"tilewidth":16,"tileheight":16,
+ "tilesets":[{"firstgid":1,"name":"collection","tilewidth":32,"tileheight":64,"tilecount":2,"columns":0,
+  "objectalignment":"center","tilerendersize":"grid","fillmode":"preserve-aspect-fit","tiles":[
+  {"id":0,"x":8,"y":16,"width":16,"height":32,"image":"roguelikeIndoor_transparent.png","imagewidth":64,"imageheight":64},
+  {"id":3,"image":"roguelikeHoliday_transparent.png","imagewidth":32,"imageheight":64}
+ ]}],
+ "layers":[{"type":"tilelayer","id":1,"name":"Ground","width":2,"height":1,"data":[1,4]}]}`))
+	if err != nil {
+		t.Fatalf("Unable to parse JSON map. Error was: %v", err)
+	}
+	checkImageCollection(t, m)
+}
+
+func TestEncodeImageCollection(t *testing.T) {
+	_, m, _ := roundTrip(t, "imageCollection.tmx")
+	checkImageCollection(t, m)
+}
+
+func checkImageCollection(t *testing.T, m Map) {
+	t.Helper()
+	ts := &m.Tilesets[0]
+	if ts.Kind() != TilesetCollection {
+		t.Errorf("Tileset kind was incorrect\nWanted: %v\nGot: %v", TilesetCollection, ts.Kind())
+	}
+	if ts.ObjectAlignment != "center" || ts.TileRenderSize != "grid" || ts.FillMode != "preserve-aspect-fit" {
+		t.Errorf("Tileset attributes were incorrect\nGot: %v %v %v", ts.ObjectAlignment, ts.TileRenderSize, ts.FillMode)
+	}
+	tests := []struct {
+		gid    uint32
+		source string
+		rect   image.Rectangle
+	}{
+		{1, "roguelikeIndoor_transparent.png", image.Rect(8, 16, 24, 48)},
+		{4, "roguelikeHoliday_transparent.png", image.Rect(0, 0, 32, 64)},
+	}
+	for _, test := range tests {
+		info, ok := m.TileForGID(test.gid)
+		if !ok {
+			t.Errorf("GID %v was not found", test.gid)
+			continue
+		}
+		if info.Image == nil || info.Image.Source != test.source || info.Rect != test.rect {
+			t.Errorf("GID %v not properly resolved\nWanted: %v %v\nGot: %v %v", test.gid, test.source, test.rect, info.Image, info.Rect)
+		}
+	}
+	// Tile IDs 1 and 2 were removed, leaving a gap
+	if info, ok := m.TileForGID(2); !ok || info.Image != nil {
+		t.Errorf("GID 2 should be in range without an image")
+	}
+	if _, ok := m.TileForGID(5); ok {
+		t.Errorf("GID 5 was found when it is out of range")
+	}
+	if len(m.Warnings) > 0 {
+		t.Errorf("Map had warnings: %v", m.Warnings)
+	}
+}
+
+func TestTilesetKind(t *testing.T) {
+	ts := Tileset{Image: []Image{Image{Source: "tiles.png"}}}
+	if ts.Kind() != TilesetImage {
+		t.Errorf("Tileset kind was incorrect\nWanted: %v\nGot: %v", TilesetImage, ts.Kind())
+	}
+	if _, _, ok := ts.TileImage(3); !ok {
+		t.Errorf("Tile of a tileset image had no image")
+	}
+}
+
+func TestRenderSize(t *testing.T) {
+	r := image.Rect(0, 0, 32, 64)
+	tests := []struct {
+		renderSize, fillMode string
+		w, h                 float64
+	}{
+		{"tile", "stretch", 32, 64},
+		{"grid", "stretch", 16, 16},
+		{"grid", "preserve-aspect-fit", 8, 16},
+	}
+	for _, test := range tests {
+		ts := Tileset{TileRenderSize: test.renderSize, FillMode: test.fillMode}
+		if w, h := ts.RenderSize(r, 16, 16); w != test.w || h != test.h {
+			t.Errorf("Render size for %v %v was incorrect\nWanted: %v %v\nGot: %v %v", test.renderSize, test.fillMode, test.w, test.h, w, h)
+		}
+	}
+}
+
+func TestAlignment(t *testing.T) {
+	tests := []struct {
+		alignment, orientation, want string
+		x, y                         float64
+	}{
+		{"unspecified", "orthogonal", "bottomleft", 0, -16},
+		{"unspecified", "isometric", "bottom", -16, -16},
+		{"center", "isometric", "center", -16, -8},
+		{"topright", "orthogonal", "topright", -32, 0},
+	}
+	for _, test := range tests {
+		ts := Tileset{ObjectAlignment: test.alignment}
+		a := ts.Alignment(test.orientation)
+		if a != test.want {
+			t.Errorf("Alignment of %v in a %v map was incorrect\nWanted: %v\nGot: %v", test.alignment, test.orientation, test.want, a)
+		}
+		if x, y := AlignmentOffset(a, 32, 16); x != test.x || y != test.y {
+			t.Errorf("Offset of %v was incorrect\nWanted: %v %v\nGot: %v %v", a, test.x, test.y, x, y)
+		}
+	}
+}
+
+func TestTileObjectBounds(t *testing.T) {
+	m, err := ParseFS(os.DirFS("testData"), "imageCollection.tmx")
+	if err != nil {
+		t.Fatalf("Unable to parse imageCollection.tmx. Error was: %v", err)
+	}
+	objects := m.ObjectGroups[0].Objects
+	tests := []struct {
+		o          *Object
+		x, y, w, h float64
+	}{
+		{&objects[0], 92, 184, 16, 32},
+		// Without a size the tile's sub-rectangle is used
+		{&objects[1], 42, 44, 16, 32},
+	}
+	for _, test := range tests {
+		x, y, w, h, ok := m.TileObjectBounds(test.o)
+		if !ok || x != test.x || y != test.y || w != test.w || h != test.h {
+			t.Errorf("Bounds of object %v were incorrect\nWanted: %v %v %v %v\nGot: %v %v %v %v", test.o.ID, test.x, test.y, test.w, test.h, x, y, w, h)
+		}
+	}
+	if _, _, _, _, ok := m.TileObjectBounds(&Object{}); ok {
+		t.Errorf("Found bounds of an object that isn't a tile")
+	}
+}
+
+func TestTileImageUnknownSize(t *testing.T) {
+	ts := Tileset{Tiles: []Tile{
+		Tile{ID: 0, Image: []Image{Image{Source: "sprite.png"}}},
+		Tile{ID: 1, Width: 8, Height: 4, Image: []Image{Image{Source: "sheet.png"}}},
+		Tile{ID: 2, X: 40, Image: []Image{Image{Source: "small.png", Width: 32, Height: 32}}},
+	}}
+	tests := []struct {
+		id   uint32
+		rect image.Rectangle
+		ok   bool
+	}{
+		{0, image.Rectangle{}, false},
+		{1, image.Rect(0, 0, 8, 4), true},
+		{2, image.Rectangle{}, false},
+	}
+	for _, test := range tests {
+		img, rect, ok := ts.TileImage(test.id)
+		if img == nil || rect != test.rect || ok != test.ok {
+			t.Errorf("Tile %v image was incorrect\nWanted: %v %v\nGot: %v %v %v", test.id, test.rect, test.ok, img, rect, ok)
+		}
+	}
+	m := Map{Tilesets: []Tileset{ts}}
+	m.Tilesets[0].FirstGID = 1
+	if _, _, _, _, ok := m.TileObjectBounds(&Object{GID: 1}); ok {
+		t.Errorf("Found bounds of a tile object without a size")
+	}
+}
+
+func TestTileForGIDConcurrent(t *testing.T) {
+	m := Map{
+		Tilesets: []Tileset{
+			Tileset{FirstGID: 1, Name: "first", TileCount: 10, Tiles: []Tile{Tile{ID: 3, Type: "wall"}}},
+		},
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if info, ok := m.TileForGID(4); !ok || info.Tile == nil || info.Tile.Type != "wall" {
+				t.Errorf("GID 4 not found in a map without a lookup table")
+			}
+		}()
+	}
+	wg.Wait()
+	if m.gids != nil {
+		t.Errorf("TileForGID built a lookup table while reading the map")
+	}
+	m.Tilesets = append(m.Tilesets, Tileset{FirstGID: 11, Name: "second", TileCount: 5})
+	m.IndexTilesets()
+	if info, ok := m.TileForGID(12); !ok || info.Tileset.Name != "second" || info.ID != 1 {
+		t.Errorf("GID 12 not found after indexing the tilesets")
+	}
+}
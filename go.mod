@@ -1 +1,5 @@
 module github.com/Noofbiz/tmx
+
+go 1.22
+
+require github.com/klauspost/compress v1.18.0
@@ -0,0 +1,848 @@
+package tmx
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// isJSON returns whether the data looks like a Tiled JSON file rather than an
+// XML one
+func isJSON(data []byte) bool {
+	data = bytes.TrimSpace(data)
+	return len(data) > 0 && data[0] == '{'
+}
+
+// jsonValue is a JSON value that is kept in the string form used by the XML
+// format. Strings are kept as is, and numbers and bools are formatted.
+type jsonValue string
+
+func (v *jsonValue) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*v = jsonValue(s)
+		return nil
+	}
+	*v = jsonValue(bytes.TrimSpace(b))
+	return nil
+}
+
+type jsonProperty struct {
+	Name         string          `json:"name"`
+	Type         string          `json:"type"`
+	PropertyType string          `json:"propertytype"`
+	Value        json.RawMessage `json:"value"`
+}
+
+func (p jsonProperty) property() (Property, error) {
+	prop := Property{
+		Name:         p.Name,
+		Type:         p.Type,
+		PropertyType: p.PropertyType,
+	}
+	if p.Type != "class" {
+		var v jsonValue
+		if len(p.Value) > 0 {
+			if err := json.Unmarshal(p.Value, &v); err != nil {
+				return prop, err
+			}
+		}
+		prop.Value = string(v)
+		return prop, nil
+	}
+	members := map[string]json.RawMessage{}
+	if len(p.Value) > 0 {
+		if err := json.Unmarshal(p.Value, &members); err != nil {
+			return prop, err
+		}
+	}
+	names := make([]string, 0, len(members))
+	for name := range members {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		member, err := jsonProperty{
+			Name:  name,
+			Type:  jsonMemberType(members[name]),
+			Value: members[name],
+		}.property()
+		if err != nil {
+			return prop, err
+		}
+		prop.Properties = append(prop.Properties, member)
+	}
+	return prop, nil
+}
+
+// jsonMemberType guesses the type of a member of a class property. The JSON
+// format doesn't store the types of members, only their values, and the types
+// are only known from the project's property types, which aren't read. So
+// color and file members are strings, and object members ints.
+func jsonMemberType(v json.RawMessage) string {
+	v = bytes.TrimSpace(v)
+	switch {
+	case len(v) == 0:
+		return ""
+	case v[0] == '{':
+		return "class"
+	case v[0] == '"':
+		return "string"
+	case bytes.Equal(v, []byte("true")), bytes.Equal(v, []byte("false")):
+		return "bool"
+	case bytes.ContainsAny(v, ".eE"):
+		return "float"
+	}
+	return "int"
+}
+
+// jsonProperties are properties in the JSON format
+type jsonProperties Properties
+
+func (ps *jsonProperties) UnmarshalJSON(b []byte) error {
+	props := []jsonProperty{}
+	if err := json.Unmarshal(b, &props); err != nil {
+		return err
+	}
+	*ps = nil
+	for _, p := range props {
+		prop, err := p.property()
+		if err != nil {
+			return err
+		}
+		*ps = append(*ps, prop)
+	}
+	return nil
+}
+
+// jsonData is the tile data of a layer or chunk. It's either an array of GIDs
+// or a base64 encoded string.
+type jsonData struct {
+	gids    []uint32
+	encoded string
+}
+
+func (d *jsonData) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, &d.encoded); err == nil {
+		return nil
+	}
+	return json.Unmarshal(b, &d.gids)
+}
+
+func (d jsonData) tiles(encoding, compression string) ([]TileData, error) {
+	if encoding == "base64" {
+		return decodeTileData(d.encoded, encoding, compression)
+	}
+	tiles := make([]TileData, len(d.gids))
+	for i, raw := range d.gids {
+		g, f := decodeGID(raw)
+		tiles[i] = TileData{
+			RawGID:   raw,
+			GID:      g,
+			Flipping: f,
+		}
+	}
+	return tiles, nil
+}
+
+type jsonChunk struct {
+	X      int      `json:"x"`
+	Y      int      `json:"y"`
+	Width  int      `json:"width"`
+	Height int      `json:"height"`
+	Data   jsonData `json:"data"`
+}
+
+type jsonText struct {
+	Text       string   `json:"text"`
+	FontFamily *string  `json:"fontfamily"`
+	PixelSize  *float64 `json:"pixelsize"`
+	Wrap       bool     `json:"wrap"`
+	Color      *string  `json:"color"`
+	Bold       bool     `json:"bold"`
+	Italic     bool     `json:"italic"`
+	Underline  bool     `json:"underline"`
+	Strikeout  bool     `json:"strikeout"`
+	Kerning    *bool    `json:"kerning"`
+	Halign     *string  `json:"halign"`
+	Valign     *string  `json:"valign"`
+}
+
+func (t jsonText) text() Text {
+	txt := Text{
+		FontFamily: "sans-serif",
+		PixelSize:  16,
+		Wrap:       boolInt(t.Wrap),
+		Color:      "#000000",
+		Bold:       boolInt(t.Bold),
+		Italic:     boolInt(t.Italic),
+		Underline:  boolInt(t.Underline),
+		Strikeout:  boolInt(t.Strikeout),
+		Kerning:    1,
+		Halign:     "left",
+		Valign:     "top",
+		CharData:   t.Text,
+	}
+	if t.FontFamily != nil {
+		txt.FontFamily = *t.FontFamily
+	}
+	if t.PixelSize != nil {
+		txt.PixelSize = *t.PixelSize
+	}
+	if t.Color != nil {
+		txt.Color = *t.Color
+	}
+	if t.Kerning != nil {
+		txt.Kerning = boolInt(*t.Kerning)
+	}
+	if t.Halign != nil {
+		txt.Halign = *t.Halign
+	}
+	if t.Valign != nil {
+		txt.Valign = *t.Valign
+	}
+	return txt
+}
+
+type jsonObject struct {
+	ID         uint32         `json:"id"`
+	Name       string         `json:"name"`
+	Type       string         `json:"type"`
+	X          float64        `json:"x"`
+	Y          float64        `json:"y"`
+	Width      float64        `json:"width"`
+	Height     float64        `json:"height"`
+	Rotation   float64        `json:"rotation"`
+	GID        uint32         `json:"gid"`
+	Visible    *bool          `json:"visible"`
+	Template   string         `json:"template"`
+	Properties jsonProperties `json:"properties"`
+	Ellipse    bool           `json:"ellipse"`
+	Point      bool           `json:"point"`
+	Capsule    bool           `json:"capsule"`
+	Polygon    Points         `json:"polygon"`
+	Polyline   Points         `json:"polyline"`
+	Text       *jsonText      `json:"text"`
+
+	// keys are the keys found in the object
+	keys []string
+}
+
+// UnmarshalJSON implements the encoding/json Unmarshaler interface, recording
+// which keys the object has so templates only fill in the others
+func (o *jsonObject) UnmarshalJSON(b []byte) error {
+	type object jsonObject
+	obj := object{}
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return err
+	}
+	*o = jsonObject(obj)
+	for k := range fields {
+		o.keys = append(o.keys, k)
+	}
+	return nil
+}
+
+func (o jsonObject) object() Object {
+	obj := Object{
+		ID:         o.ID,
+		Name:       o.Name,
+		Type:       o.Type,
+		X:          o.X,
+		Y:          o.Y,
+		Width:      o.Width,
+		Height:     o.Height,
+		Rotation:   o.Rotation,
+		GID:        o.GID,
+		Visible:    1,
+		Template:   o.Template,
+		Properties: Properties(o.Properties),
+	}
+	if o.Visible != nil {
+		obj.Visible = boolInt(*o.Visible)
+	}
+	if o.Ellipse {
+		obj.Ellipses = []Ellipse{Ellipse{}}
+	}
+	if o.Point {
+		obj.Points = []PointShape{PointShape{}}
+	}
+	if o.Capsule {
+		obj.Capsules = []Capsule{Capsule{}}
+	}
+	if o.Polygon != nil {
+		obj.Polygons = []Polygon{Polygon{Points: o.Polygon}}
+	}
+	if o.Polyline != nil {
+		obj.Polylines = []Polyline{Polyline{Points: o.Polyline}}
+	}
+	if o.Text != nil {
+		obj.Text = []Text{o.Text.text()}
+	}
+	obj.setOverrides(o.keys)
+	return obj
+}
+
+type jsonLayer struct {
+	Type        string         `json:"type"`
+	ID          int            `json:"id"`
+	Name        string         `json:"name"`
+	Class       string         `json:"class"`
+	X           float64        `json:"x"`
+	Y           float64        `json:"y"`
+	Width       int            `json:"width"`
+	Height      int            `json:"height"`
+	Opacity     *float64       `json:"opacity"`
+	Visible     *bool          `json:"visible"`
+	OffsetX     float64        `json:"offsetx"`
+	OffsetY     float64        `json:"offsety"`
+	ParallaxX   *float64       `json:"parallaxx"`
+	ParallaxY   *float64       `json:"parallaxy"`
+	TintColor   string         `json:"tintcolor"`
+	Locked      bool           `json:"locked"`
+	RepeatX     bool           `json:"repeatx"`
+	RepeatY     bool           `json:"repeaty"`
+	Properties  jsonProperties `json:"properties"`
+	Encoding    string         `json:"encoding"`
+	Compression string         `json:"compression"`
+	Data        *jsonData      `json:"data"`
+	Chunks      []jsonChunk    `json:"chunks"`
+	Color       string         `json:"color"`
+	DrawOrder   string         `json:"draworder"`
+	Objects     []jsonObject   `json:"objects"`
+	Image       string         `json:"image"`
+	Transparent string         `json:"transparentcolor"`
+	Layers      []jsonLayer    `json:"layers"`
+}
+
+func (l jsonLayer) opacity() float64 {
+	if l.Opacity == nil {
+		return 1
+	}
+	return *l.Opacity
+}
+
+func (l jsonLayer) parallax() (float64, float64) {
+	x, y := 1.0, 1.0
+	if l.ParallaxX != nil {
+		x = *l.ParallaxX
+	}
+	if l.ParallaxY != nil {
+		y = *l.ParallaxY
+	}
+	return x, y
+}
+
+func (l jsonLayer) visible() int {
+	if l.Visible == nil {
+		return 1
+	}
+	return boolInt(*l.Visible)
+}
+
+func (l jsonLayer) layer() (Layer, error) {
+	encoding := l.Encoding
+	if encoding == "" {
+		encoding = "csv"
+	}
+	da := Data{
+		Encoding:    encoding,
+		Compression: l.Compression,
+	}
+	var err error
+	if l.Data != nil {
+		if da.Tiles, err = l.Data.tiles(encoding, l.Compression); err != nil {
+			return Layer{}, errorAt(err, "data", 0)
+		}
+	}
+	for _, c := range l.Chunks {
+		chunk := Chunk{
+			X:      c.X,
+			Y:      c.Y,
+			Width:  c.Width,
+			Height: c.Height,
+		}
+		if chunk.Tiles, err = c.Data.tiles(encoding, l.Compression); err != nil {
+			return Layer{}, errorAt(errorAt(err, chunk.path(), 0), "data", 0)
+		}
+		da.Chunks = append(da.Chunks, chunk)
+	}
+	px, py := l.parallax()
+	return Layer{
+		ID:         l.ID,
+		Name:       l.Name,
+		Class:      l.Class,
+		X:          l.X,
+		Y:          l.Y,
+		Width:      l.Width,
+		Height:     l.Height,
+		Opacity:    l.opacity(),
+		Visible:    l.visible(),
+		OffsetX:    l.OffsetX,
+		OffsetY:    l.OffsetY,
+		ParallaxX:  px,
+		ParallaxY:  py,
+		TintColor:  l.TintColor,
+		Locked:     boolInt(l.Locked),
+		Properties: Properties(l.Properties),
+		Data:       []Data{da},
+	}, nil
+}
+
+func (l jsonLayer) objectGroup() ObjectGroup {
+	px, py := l.parallax()
+	og := ObjectGroup{
+		ID:         l.ID,
+		Name:       l.Name,
+		Class:      l.Class,
+		Color:      l.Color,
+		X:          int(l.X),
+		Y:          int(l.Y),
+		Width:      l.Width,
+		Opacity:    l.opacity(),
+		Visible:    l.visible(),
+		OffsetX:    l.OffsetX,
+		OffsetY:    l.OffsetY,
+		ParallaxX:  px,
+		ParallaxY:  py,
+		TintColor:  l.TintColor,
+		Locked:     boolInt(l.Locked),
+		DrawOrder:  l.DrawOrder,
+		Properties: Properties(l.Properties),
+	}
+	if og.DrawOrder == "" {
+		og.DrawOrder = "topdown"
+	}
+	for _, o := range l.Objects {
+		og.Objects = append(og.Objects, o.object())
+	}
+	return og
+}
+
+func (l jsonLayer) imageLayer() ImageLayer {
+	px, py := l.parallax()
+	il := ImageLayer{
+		ID:         l.ID,
+		Name:       l.Name,
+		Class:      l.Class,
+		OffsetX:    l.OffsetX,
+		OffsetY:    l.OffsetY,
+		X:          l.X,
+		Y:          l.Y,
+		Opacity:    l.opacity(),
+		Visible:    l.visible(),
+		ParallaxX:  px,
+		ParallaxY:  py,
+		TintColor:  l.TintColor,
+		Locked:     boolInt(l.Locked),
+		RepeatX:    boolInt(l.RepeatX),
+		RepeatY:    boolInt(l.RepeatY),
+		Properties: Properties(l.Properties),
+	}
+	if l.Image != "" {
+		il.Images = []Image{Image{
+			Source:      l.Image,
+			Transparent: l.Transparent,
+		}}
+	}
+	return il
+}
+
+func (l jsonLayer) group() (Group, error) {
+	px, py := l.parallax()
+	g := Group{
+		ID:         l.ID,
+		Name:       l.Name,
+		Class:      l.Class,
+		OffsetX:    l.OffsetX,
+		OffsetY:    l.OffsetY,
+		Opacity:    l.opacity(),
+		Visible:    l.visible(),
+		ParallaxX:  px,
+		ParallaxY:  py,
+		TintColor:  l.TintColor,
+		Locked:     boolInt(l.Locked),
+		Properties: Properties(l.Properties),
+	}
+	var err error
+	g.Layers, g.ObjectGroups, g.ImageLayers, g.Group, err = jsonLayers(l.Layers)
+	return g, err
+}
+
+// jsonLayers sorts the layers of a map or group into their kinds, keeping
+// their order in Index
+func jsonLayers(layers []jsonLayer) (ls []Layer, ogs []ObjectGroup, ils []ImageLayer, gs []Group, err error) {
+	for i, l := range layers {
+		switch l.Type {
+		case "tilelayer":
+			la, err := l.layer()
+			if err != nil {
+				return ls, ogs, ils, gs, errorAt(err, elementPath("layer", l.Name), 0)
+			}
+			la.Index = i
+			ls = append(ls, la)
+		case "objectgroup":
+			og := l.objectGroup()
+			og.Index = i
+			ogs = append(ogs, og)
+		case "imagelayer":
+			il := l.imageLayer()
+			il.Index = i
+			ils = append(ils, il)
+		case "group":
+			g, err := l.group()
+			if err != nil {
+				return ls, ogs, ils, gs, errorAt(err, elementPath("group", l.Name), 0)
+			}
+			g.Index = i
+			gs = append(gs, g)
+		default:
+			return ls, ogs, ils, gs, &ValidationError{Path: elementPath(l.Type, l.Name), Err: ErrUnknownLayerType}
+		}
+	}
+	return ls, ogs, ils, gs, nil
+}
+
+type jsonFrame struct {
+	TileID   uint32  `json:"tileid"`
+	Duration float64 `json:"duration"`
+}
+
+type jsonTile struct {
+	ID          uint32         `json:"id"`
+	Type        string         `json:"type"`
+	X           int            `json:"x"`
+	Y           int            `json:"y"`
+	Width       int            `json:"width"`
+	Height      int            `json:"height"`
+	Terrain     []int          `json:"terrain"`
+	Probability *float64       `json:"probability"`
+	Properties  jsonProperties `json:"properties"`
+	Image       string         `json:"image"`
+	ImageWidth  float64        `json:"imagewidth"`
+	ImageHeight float64        `json:"imageheight"`
+	ObjectGroup *jsonLayer     `json:"objectgroup"`
+	Animation   []jsonFrame    `json:"animation"`
+}
+
+func (t jsonTile) tile() Tile {
+	tile := Tile{
+		ID:          t.ID,
+		Type:        t.Type,
+		X:           t.X,
+		Y:           t.Y,
+		Width:       t.Width,
+		Height:      t.Height,
+		Probability: 1,
+		Properties:  Properties(t.Properties),
+	}
+	if t.Probability != nil {
+		tile.Probability = *t.Probability
+	}
+	if t.Terrain != nil {
+		terrain := make([]string, len(t.Terrain))
+		for i, te := range t.Terrain {
+			if te >= 0 {
+				terrain[i] = strconv.Itoa(te)
+			}
+		}
+		tile.Terrain = strings.Join(terrain, ",")
+	}
+	if t.Image != "" {
+		tile.Image = []Image{Image{
+			Source: t.Image,
+			Width:  t.ImageWidth,
+			Height: t.ImageHeight,
+		}}
+	}
+	if t.ObjectGroup != nil {
+		tile.ObjectGroup = []ObjectGroup{t.ObjectGroup.objectGroup()}
+	}
+	for _, f := range t.Animation {
+		tile.AnimationFrames = append(tile.AnimationFrames, Frame{
+			TileID:   f.TileID,
+			Duration: f.Duration,
+		})
+	}
+	return tile
+}
+
+type jsonWangColor struct {
+	Name        string         `json:"name"`
+	Class       string         `json:"class"`
+	Color       string         `json:"color"`
+	Tile        int            `json:"tile"`
+	Probability float64        `json:"probability"`
+	Properties  jsonProperties `json:"properties"`
+}
+
+type jsonWangTile struct {
+	TileID uint32 `json:"tileid"`
+	WangID []int  `json:"wangid"`
+	HFlip  bool   `json:"hflip"`
+	VFlip  bool   `json:"vflip"`
+	DFlip  bool   `json:"dflip"`
+}
+
+type jsonWangSet struct {
+	Name             string          `json:"name"`
+	Class            string          `json:"class"`
+	Type             string          `json:"type"`
+	Tile             int             `json:"tile"`
+	Properties       jsonProperties  `json:"properties"`
+	Colors           []jsonWangColor `json:"colors"`
+	WangCornerColors []jsonWangColor `json:"cornercolors"`
+	WangEdgeColors   []jsonWangColor `json:"edgecolors"`
+	WangTiles        []jsonWangTile  `json:"wangtiles"`
+}
+
+func (w jsonWangSet) wangSet() (WangSet, error) {
+	ws := WangSet{
+		Name:       w.Name,
+		Class:      w.Class,
+		Type:       w.Type,
+		Tile:       w.Tile,
+		Properties: Properties(w.Properties),
+	}
+	for _, c := range w.Colors {
+		ws.Colors = append(ws.Colors, WangColor{
+			Name:        c.Name,
+			Class:       c.Class,
+			Color:       c.Color,
+			Tile:        c.Tile,
+			Probability: c.Probability,
+			Properties:  Properties(c.Properties),
+		})
+	}
+	for _, c := range w.WangCornerColors {
+		ws.WangCornerColors = append(ws.WangCornerColors, WangCornerColor{Name: c.Name, Color: c.Color, Tile: c.Tile, Probability: c.Probability})
+	}
+	for _, c := range w.WangEdgeColors {
+		ws.WangEdgeColors = append(ws.WangEdgeColors, WangEdgeColor{Name: c.Name, Color: c.Color, Tile: c.Tile, Probability: c.Probability})
+	}
+	for _, t := range w.WangTiles {
+		wt := WangTile{
+			TileID: t.TileID,
+			HFlip:  t.HFlip,
+			VFlip:  t.VFlip,
+			DFlip:  t.DFlip,
+		}
+		if len(t.WangID) != len(wt.WangID) {
+			return ws, &DecodeError{Path: elementPath("wangset", w.Name), Err: ErrInvalidWangID}
+		}
+		for i, c := range t.WangID {
+			if c < 0 || c > 255 {
+				return ws, &DecodeError{Path: elementPath("wangset", w.Name), Err: ErrInvalidWangID}
+			}
+			wt.WangID[i] = uint8(c)
+		}
+		ws.WangTiles = append(ws.WangTiles, wt)
+	}
+	ws.convertLegacy()
+	return ws, nil
+}
+
+type jsonTransform struct {
+	HFlip               bool `json:"hflip"`
+	VFlip               bool `json:"vflip"`
+	Rotate              bool `json:"rotate"`
+	PreferUntransformed bool `json:"preferuntransformed"`
+}
+
+func (t jsonTransform) transformations() Transformations {
+	return Transformations{
+		HFlip:               boolInt(t.HFlip),
+		VFlip:               boolInt(t.VFlip),
+		Rotate:              boolInt(t.Rotate),
+		PreferUntransformed: boolInt(t.PreferUntransformed),
+	}
+}
+
+type jsonTileset struct {
+	FirstGID    uint32         `json:"firstgid"`
+	Source      string         `json:"source"`
+	Name        string         `json:"name"`
+	TileWidth   int            `json:"tilewidth"`
+	TileHeight  int            `json:"tileheight"`
+	Spacing     int            `json:"spacing"`
+	Margin      float64        `json:"margin"`
+	TileCount   int            `json:"tilecount"`
+	Columns     int            `json:"columns"`
+	Alignment   string         `json:"objectalignment"`
+	RenderSize  string         `json:"tilerendersize"`
+	FillMode    string         `json:"fillmode"`
+	TileOffset  *TileOffset    `json:"tileoffset"`
+	Grid        *Grid          `json:"grid"`
+	Transforms  *jsonTransform `json:"transformations"`
+	Properties  jsonProperties `json:"properties"`
+	Image       string         `json:"image"`
+	ImageWidth  float64        `json:"imagewidth"`
+	ImageHeight float64        `json:"imageheight"`
+	Transparent string         `json:"transparentcolor"`
+	Terrains    []Terrain      `json:"terrains"`
+	Tiles       []jsonTile     `json:"tiles"`
+	WangSets    []jsonWangSet  `json:"wangsets"`
+}
+
+// UnmarshalJSON implements the encoding/json Unmarshaler interface for
+// tilesets in Tiled's JSON format
+func (t *Tileset) UnmarshalJSON(b []byte) error {
+	ts := jsonTileset{}
+	if err := json.Unmarshal(b, &ts); err != nil {
+		return errorAt(err, "tileset", 0)
+	}
+	*t = Tileset{
+		FirstGID:        ts.FirstGID,
+		Source:          ts.Source,
+		Name:            ts.Name,
+		TileWidth:       ts.TileWidth,
+		TileHeight:      ts.TileHeight,
+		Spacing:         ts.Spacing,
+		Margin:          ts.Margin,
+		TileCount:       ts.TileCount,
+		Columns:         ts.Columns,
+		Properties:      Properties(ts.Properties),
+		ObjectAlignment: ts.Alignment,
+		TileRenderSize:  ts.RenderSize,
+		FillMode:        ts.FillMode,
+	}
+	if t.ObjectAlignment == "" {
+		t.ObjectAlignment = "unspecified"
+	}
+	if t.TileRenderSize == "" {
+		t.TileRenderSize = "tile"
+	}
+	if t.FillMode == "" {
+		t.FillMode = "stretch"
+	}
+	if ts.TileOffset != nil {
+		t.TileOffset = []TileOffset{*ts.TileOffset}
+	}
+	if ts.Grid != nil {
+		t.Grid = []Grid{*ts.Grid}
+	}
+	if ts.Transforms != nil {
+		t.Transformations = []Transformations{ts.Transforms.transformations()}
+	}
+	if ts.Image != "" {
+		t.Image = []Image{Image{
+			Source:      ts.Image,
+			Transparent: ts.Transparent,
+			Width:       ts.ImageWidth,
+			Height:      ts.ImageHeight,
+		}}
+	}
+	t.TerrainTypes = ts.Terrains
+	for _, tile := range ts.Tiles {
+		t.Tiles = append(t.Tiles, tile.tile())
+	}
+	for _, w := range ts.WangSets {
+		ws, err := w.wangSet()
+		if err != nil {
+			return errorAt(err, "tileset", 0)
+		}
+		t.WangSets = append(t.WangSets, ws)
+	}
+	return nil
+}
+
+type jsonMap struct {
+	Version          jsonValue      `json:"version"`
+	TiledVersion     string         `json:"tiledversion"`
+	Orientation      string         `json:"orientation"`
+	RenderOrder      string         `json:"renderorder"`
+	Width            int            `json:"width"`
+	Height           int            `json:"height"`
+	TileWidth        int            `json:"tilewidth"`
+	TileHeight       int            `json:"tileheight"`
+	Infinite         bool           `json:"infinite"`
+	HexSideLength    int            `json:"hexsidelength"`
+	StaggerAxis      string         `json:"staggeraxis"`
+	StaggerIndex     string         `json:"staggerindex"`
+	BackgroundColor  string         `json:"backgroundcolor"`
+	Class            string         `json:"class"`
+	ParallaxOriginX  float64        `json:"parallaxoriginx"`
+	ParallaxOriginY  float64        `json:"parallaxoriginy"`
+	CompressionLevel *int           `json:"compressionlevel"`
+	NextLayerID      int            `json:"nextlayerid"`
+	NextObjectID     int            `json:"nextobjectid"`
+	Properties       jsonProperties `json:"properties"`
+	Tilesets         []Tileset      `json:"tilesets"`
+	Layers           []jsonLayer    `json:"layers"`
+}
+
+// UnmarshalJSON implements the encoding/json Unmarshaler interface for maps
+// in Tiled's JSON format
+func (m *Map) UnmarshalJSON(b []byte) error {
+	ma := jsonMap{}
+	if err := json.Unmarshal(b, &ma); err != nil {
+		return errorAt(err, "map", 0)
+	}
+	*m = Map{
+		Version:          string(ma.Version),
+		TiledVersion:     ma.TiledVersion,
+		Orientation:      ma.Orientation,
+		RenderOrder:      ma.RenderOrder,
+		Width:            ma.Width,
+		Height:           ma.Height,
+		TileWidth:        ma.TileWidth,
+		TileHeight:       ma.TileHeight,
+		Infinite:         boolInt(ma.Infinite),
+		HexSideLength:    ma.HexSideLength,
+		StaggerAxis:      ma.StaggerAxis,
+		StaggerIndex:     ma.StaggerIndex,
+		BackgroundColor:  ma.BackgroundColor,
+		Class:            ma.Class,
+		ParallaxOriginX:  ma.ParallaxOriginX,
+		ParallaxOriginY:  ma.ParallaxOriginY,
+		CompressionLevel: -1,
+		NextLayerID:      ma.NextLayerID,
+		NextObjectID:     ma.NextObjectID,
+		Properties:       Properties(ma.Properties),
+		Tilesets:         ma.Tilesets,
+	}
+	if m.RenderOrder == "" {
+		m.RenderOrder = "right-down"
+	}
+	if ma.CompressionLevel != nil {
+		m.CompressionLevel = *ma.CompressionLevel
+	}
+	var err error
+	m.Layers, m.ObjectGroups, m.ImageLayers, m.Groups, err = jsonLayers(ma.Layers)
+	return errorAt(err, "map", 0)
+}
+
+type jsonTemplate struct {
+	Tileset *Tileset    `json:"tileset"`
+	Object  *jsonObject `json:"object"`
+}
+
+// UnmarshalJSON implements the encoding/json Unmarshaler interface for
+// templates in Tiled's JSON format
+func (t *Template) UnmarshalJSON(b []byte) error {
+	tmpl := jsonTemplate{}
+	if err := json.Unmarshal(b, &tmpl); err != nil {
+		return errorAt(err, "template", 0)
+	}
+	*t = Template{}
+	if tmpl.Object != nil {
+		t.Objects = []Object{tmpl.Object.object()}
+	}
+	if tmpl.Tileset != nil {
+		t.Tilesets = []Tileset{*tmpl.Tileset}
+	}
+	return nil
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,116 @@
+package tmx
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParseJSON(t *testing.T) {
+	TMXURL = "testData/jsonMap.tmj"
+	f, err := os.Open(TMXURL)
+	if err != nil {
+		t.Errorf("Unable to open %v. Error was: %v", TMXURL, err)
+		return
+	}
+	defer f.Close()
+	m, err := ParseJSON(f)
+	if err != nil {
+		t.Errorf("Unable to parse %v. Error was: %v", TMXURL, err)
+		return
+	}
+	if m.Version != "1.8" || m.Width != 3 || m.TileWidth != 16 || m.RenderOrder != "right-down" {
+		t.Errorf("Map attributes not properly parsed. Got: %v %v %v %v", m.Version, m.Width, m.TileWidth, m.RenderOrder)
+	}
+	for i, e := range testDataExpected {
+		if m.Layers[0].Data[0].Tiles[i].RawGID != e {
+			t.Errorf("Decoded JSON data does not match GIDs\nWanted: %v\nGot: %v", e, m.Layers[0].Data[0].Tiles[i].RawGID)
+			return
+		}
+	}
+	if m.Tilesets[0].Image[0].Source != "roguelikeIndoor_transparent.png" {
+		t.Errorf("Image not properly parsed from embedded tileset")
+	}
+	if len(m.Tilesets[0].Tiles[0].AnimationFrames) != 2 || m.Tilesets[0].Tiles[0].AnimationFrames[1].Duration != 200 {
+		t.Errorf("Tile animation not properly parsed")
+	}
+	if m.Tilesets[0].Tiles[0].Properties[0].Value != "false" {
+		t.Errorf("Tile property not properly parsed\nWanted: %v\nGot: %v", "false", m.Tilesets[0].Tiles[0].Properties[0].Value)
+	}
+	if m.Tilesets[1].Image[0].Source != "roguelikeHoliday_transparent.png" {
+		t.Errorf("Image not properly parsed from external tileset")
+	}
+	objs := m.ObjectGroups[0].Objects
+	props := objs[0].Properties
+	if props[0].Value != "3" || props[1].Value != "true" || props[2].Value != "This is an attribute value" {
+		t.Errorf("Object properties not properly parsed. Got: %v", props)
+	}
+	if objs[1].Name != "Wheel" || objs[1].Width != 15 || len(objs[1].Ellipses) != 1 || objs[1].X != 26 {
+		t.Errorf("Object template not properly applied. Got: %+v", objs[1])
+	}
+	pts := objs[2].Polygons[0].Points
+	if len(pts) != 3 || pts[1] != (Point{X: 10, Y: 5}) || pts[2] != (Point{X: -3.5, Y: 8}) || objs[2].Visible != 0 {
+		t.Errorf("Polygon not properly parsed. Got: %+v", objs[2])
+	}
+	if objs[3].Text[0].CharData != "Hello World" || objs[3].Text[0].Wrap != 1 || objs[3].Text[0].Kerning != 1 {
+		t.Errorf("Text not properly parsed. Got: %+v", objs[3].Text[0])
+	}
+	g := m.Groups[0]
+	if g.Name != "Group 1" || g.Visible != 0 || g.OffsetX != 2 {
+		t.Errorf("Group not properly parsed. Got: %+v", g)
+	}
+	if g.ImageLayers[0].Opacity != 0.5 || g.ImageLayers[0].Images[0].Source != "roguelikeHoliday_transparent.png" {
+		t.Errorf("Image layer not properly parsed. Got: %+v", g.ImageLayers[0])
+	}
+}
+
+func TestParseJSONChunks(t *testing.T) {
+	m, err := ParseFS(os.DirFS("testData"), "jsonChunkData.tmj")
+	if err != nil {
+		t.Errorf("Unable to parse jsonChunkData.tmj. Error was: %v", err)
+		return
+	}
+	if m.Infinite != 1 {
+		t.Errorf("Infinite not properly parsed\nWanted: %v\nGot: %v", 1, m.Infinite)
+	}
+	if m.Version != "1.2" {
+		t.Errorf("Numeric version not properly parsed\nWanted: %v\nGot: %v", "1.2", m.Version)
+	}
+	c := m.Layers[0].Data[0].Chunks[0]
+	if c.X != -32 || c.Y != -16 || c.Width != 3 || c.Height != 3 {
+		t.Errorf("Chunk not properly parsed. Got: %v,%v %vx%v", c.X, c.Y, c.Width, c.Height)
+	}
+	for _, tiles := range [][]TileData{c.Tiles, m.Layers[1].Data[0].Tiles} {
+		for i, e := range testDataExpected {
+			if tiles[i].RawGID != e {
+				t.Errorf("Decoded base64 data does not match GIDs\nWanted: %v\nGot: %v", e, tiles[i].RawGID)
+				return
+			}
+		}
+	}
+}
+
+func TestParseJSONMalformed(t *testing.T) {
+	for _, name := range []string{"malformedJSON.tmj", "jsonTSJNotExist.tmj"} {
+		if _, err := ParseFS(os.DirFS("testData"), name); err == nil {
+			t.Errorf("Able to parse %v", name)
+		}
+	}
+	if _, err := ParseJSON(strings.NewReader(`<map></map>`)); err == nil {
+		t.Errorf("Able to parse XML as JSON")
+	}
+	if _, err := ParseJSON(strings.NewReader(`{"layers":[{"type":"unknown"}]}`)); err == nil {
+		t.Errorf("Able to parse an unknown layer type")
+	}
+}
+
+func TestParseTMXWithJSONTileset(t *testing.T) {
+	m, err := NewLoader(os.DirFS("testData")).Decode(strings.NewReader(`<map><tileset firstgid="1" source="external.tsj"/></map>`), "map.tmx")
+	if err != nil {
+		t.Errorf("Unable to parse a map with a JSON tileset. Error was: %v", err)
+		return
+	}
+	if m.Tilesets[0].Name != "external" {
+		t.Errorf("JSON tileset not properly loaded\nWanted: %v\nGot: %v", "external", m.Tilesets[0].Name)
+	}
+}
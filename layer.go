@@ -1,11 +1,22 @@
 package tmx
 
-import "encoding/xml"
+import (
+	"encoding/xml"
+	"image"
+	"image/color"
+	"sort"
+	"strconv"
+)
 
 // Layer is a layer of the map
 type Layer struct {
 	// Name is the name of the layer
 	Name string `xml:"name,attr"`
+	// ID is the unique ID of the layer. Maps saved before Tiled 1.2 don't
+	// have layer IDs, so it's 0.
+	ID int `xml:"id,attr"`
+	// Class is the class of the layer
+	Class string `xml:"class,attr"`
 	// X is the x coordinate of the layer
 	X float64 `xml:"x,attr,omitempty"`
 	// Y is the y coordinate of the layer
@@ -24,22 +35,480 @@ type Layer struct {
 	OffsetX float64 `xml:"offsetx,attr,omitempty"`
 	// OffsetY is the rendering offset for this layer in pixels.
 	OffsetY float64 `xml:"offsety,attr,omitempty"`
+	// ParallaxX is the horizontal parallax scrolling factor of the layer.
+	// Defaults to 1.
+	ParallaxX float64 `xml:"parallaxx,attr"`
+	// ParallaxY is the vertical parallax scrolling factor of the layer.
+	// Defaults to 1.
+	ParallaxY float64 `xml:"parallaxy,attr"`
+	// TintColor is a color that is multiplied with the tiles of the layer, in
+	// the form #AARRGGBB or #RRGGBB
+	TintColor string `xml:"tintcolor,attr"`
+	// Locked is whether the layer is locked in the editor (1) or not (0)
+	Locked int `xml:"locked,attr"`
 	// Properties are the properties of the layer
-	Properties []Property `xml:"properties,omitempty>property"`
+	Properties Properties `xml:"properties>property"`
 	// Data is any data for the layer
 	Data []Data `xml:"data"`
+	// Index is the position of the layer among the layers of its map or group,
+	// in the order they appear in the file
+	Index int `xml:"-"`
+
+	// offset is where the layer was found in the file
+	offset int64
+	// chunks finds the layer's chunks by their position, built by
+	// IndexChunks
+	chunks *chunkIndex
 }
 
 // UnmarshalXML implements the encoding/xml Unmarshaler interface
 func (l *Layer) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 	type layer Layer
 	la := layer{
-		Opacity: 1,
-		Visible: 1,
+		Opacity:   1,
+		Visible:   1,
+		ParallaxX: 1,
+		ParallaxY: 1,
+		offset:    d.InputOffset(),
 	}
+	line := currentLine(d)
 	if err := d.DecodeElement(&la, &start); err != nil {
-		return err
+		return errorAt(err, elementPath("layer", la.Name), line)
 	}
 	*l = (Layer)(la)
 	return nil
 }
+
+// MarshalXML implements the encoding/xml Marshaler interface
+func (l Layer) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	a := attrs{}
+	a.int("id", l.ID, 0)
+	a.add("name", l.Name)
+	a.str("class", l.Class, "")
+	a.float("x", l.X, 0)
+	a.float("y", l.Y, 0)
+	a.add("width", strconv.Itoa(l.Width))
+	a.add("height", strconv.Itoa(l.Height))
+	a.float("opacity", l.Opacity, 1)
+	a.int("visible", l.Visible, 1)
+	a.float("offsetx", l.OffsetX, 0)
+	a.float("offsety", l.OffsetY, 0)
+	a.float("parallaxx", l.ParallaxX, 1)
+	a.float("parallaxy", l.ParallaxY, 1)
+	a.str("tintcolor", l.TintColor, "")
+	a.int("locked", l.Locked, 0)
+	start, err := encodeStart(e, start, a)
+	if err != nil {
+		return err
+	}
+	if err = encodeProperties(e, l.Properties); err != nil {
+		return err
+	}
+	for _, da := range l.Data {
+		if err = da.encode(e, startElement("data"), l.Width); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// TileAt returns the tile at the tile coordinates x, y. The coordinates of
+// layers in infinite maps can be negative. It returns false if the layer has
+// no tile at the coordinates.
+//
+// Layers read by a Loader find the chunk holding x, y straight away. Layers
+// built in code search their chunks one by one, unless IndexChunks is called.
+func (l *Layer) TileAt(x, y int) (TileData, bool) {
+	for _, da := range l.Data {
+		if len(da.Chunks) == 0 {
+			if x < 0 || y < 0 || x >= l.Width || y >= l.Height {
+				continue
+			}
+			if i := y*l.Width + x; i < len(da.Tiles) {
+				return da.Tiles[i], true
+			}
+			continue
+		}
+		if l.chunks != nil {
+			continue
+		}
+		for _, c := range da.Chunks {
+			if t, ok := c.TileAt(x, y); ok {
+				return t, true
+			}
+		}
+	}
+	if l.chunks != nil {
+		return l.chunks.tileAt(x, y)
+	}
+	return TileData{}, false
+}
+
+// chunkIndex finds the chunks of a layer by their position. It's only used
+// for layers whose chunks are all the same size and aligned to a grid of that
+// size, which is how Tiled writes them.
+type chunkIndex struct {
+	size   image.Point
+	chunks map[image.Point]*Chunk
+}
+
+func (ci *chunkIndex) tileAt(x, y int) (TileData, bool) {
+	o := image.Pt(x-floorMod(x, ci.size.X), y-floorMod(y, ci.size.Y))
+	if c, ok := ci.chunks[o]; ok {
+		return c.TileAt(x, y)
+	}
+	return TileData{}, false
+}
+
+func floorMod(a, b int) int {
+	return (a%b + b) % b
+}
+
+// IndexChunks builds the table TileAt uses to find the chunk holding a tile.
+// Layers read by a Loader are indexed already. Call it after building a layer
+// in code, or after changing its Data, since the table doesn't see changes
+// made after it's built. Layers whose chunks aren't all the same size and
+// aligned to a grid of that size aren't indexed.
+func (l *Layer) IndexChunks() {
+	l.chunks = nil
+	var ci *chunkIndex
+	for i := range l.Data {
+		for j := range l.Data[i].Chunks {
+			c := &l.Data[i].Chunks[j]
+			if ci == nil {
+				if c.Width <= 0 || c.Height <= 0 {
+					return
+				}
+				ci = &chunkIndex{size: image.Pt(c.Width, c.Height), chunks: make(map[image.Point]*Chunk)}
+			}
+			if c.Width != ci.size.X || c.Height != ci.size.Y || floorMod(c.X, c.Width) != 0 || floorMod(c.Y, c.Height) != 0 {
+				return
+			}
+			if _, ok := ci.chunks[image.Pt(c.X, c.Y)]; !ok {
+				ci.chunks[image.Pt(c.X, c.Y)] = c
+			}
+		}
+	}
+	l.chunks = ci
+}
+
+// EachChunk calls fn for every chunk of the layer, in the order they are in
+// the file. The tiles of layers without chunks are given as one chunk covering
+// the whole layer. Unlike Flatten, it doesn't allocate a grid covering Bounds,
+// so it suits infinite maps with chunks far apart.
+func (l *Layer) EachChunk(fn func(c *Chunk)) {
+	for i := range l.Data {
+		da := &l.Data[i]
+		if len(da.Chunks) == 0 {
+			fn(&Chunk{Width: l.Width, Height: l.Height, Tiles: da.Tiles})
+			continue
+		}
+		for j := range da.Chunks {
+			fn(&da.Chunks[j])
+		}
+	}
+}
+
+// Bounds returns the area covered by the tiles of the layer, in tiles. For
+// layers with chunks it's the smallest rectangle that holds every chunk.
+func (l *Layer) Bounds() image.Rectangle {
+	var b image.Rectangle
+	l.EachChunk(func(c *Chunk) {
+		b = b.Union(c.Bounds())
+	})
+	return b
+}
+
+// Flatten returns the tiles of the layer as one dense grid covering Bounds,
+// row by row. The returned rectangle is the bounds of the grid, so the tile at
+// x, y is at index (y-b.Min.Y)*b.Dx() + x-b.Min.X. Any cells that aren't in a
+// chunk are left empty. The grid covers the space between chunks too, so use
+// EachChunk or EachTile for infinite maps with chunks far apart.
+func (l *Layer) Flatten() ([]TileData, image.Rectangle) {
+	b := l.Bounds()
+	tiles := make([]TileData, b.Dx()*b.Dy())
+	var chunks []*Chunk
+	l.EachChunk(func(c *Chunk) {
+		chunks = append(chunks, c)
+	})
+	// Chunks are copied last to first, so where chunks overlap the grid
+	// holds the tile TileAt finds
+	for i := len(chunks) - 1; i >= 0; i-- {
+		c := chunks[i]
+		for r := 0; r < c.Height && r*c.Width < len(c.Tiles); r++ {
+			row := c.Tiles[r*c.Width:]
+			if len(row) > c.Width {
+				row = row[:c.Width]
+			}
+			start := (c.Y+r-b.Min.Y)*b.Dx() + c.X - b.Min.X
+			copy(tiles[start:start+len(row)], row)
+		}
+	}
+	return tiles, b
+}
+
+// EachTile calls fn for every tile of the layer that isn't empty, with its
+// tile coordinates, in the order given by renderOrder. It's one of
+// "right-down" (the default, used if renderOrder is empty), "right-up",
+// "left-down" or "left-up", as in Map.RenderOrder, and drawing the tiles in
+// this order makes tiles taller than a cell overlap the way they do in Tiled.
+// Layers with chunks are visited a row at a time across the chunks, without
+// going over the space between them.
+func (l *Layer) EachTile(renderOrder string, fn func(x, y int, t TileData)) {
+	left := renderOrder == "left-down" || renderOrder == "left-up"
+	up := renderOrder == "right-up" || renderOrder == "left-up"
+	var chunks []*Chunk
+	l.EachChunk(func(c *Chunk) {
+		chunks = append(chunks, c)
+	})
+	sort.SliceStable(chunks, func(i, j int) bool {
+		if left {
+			return chunks[i].X > chunks[j].X
+		}
+		return chunks[i].X < chunks[j].X
+	})
+	rows := make(map[int][]*Chunk)
+	for _, c := range chunks {
+		for y := c.Y; y < c.Y+c.Height; y++ {
+			rows[y] = append(rows[y], c)
+		}
+	}
+	ys := make([]int, 0, len(rows))
+	for y := range rows {
+		ys = append(ys, y)
+	}
+	sort.Ints(ys)
+	if up {
+		sort.Sort(sort.Reverse(sort.IntSlice(ys)))
+	}
+	for _, y := range ys {
+		for _, c := range rows[y] {
+			i := (y - c.Y) * c.Width
+			if i >= len(c.Tiles) {
+				continue
+			}
+			row := c.Tiles[i:]
+			if len(row) > c.Width {
+				row = row[:c.Width]
+			}
+			for j := range row {
+				if left {
+					j = len(row) - 1 - j
+				}
+				if t := row[j]; t.GID != 0 {
+					fn(c.X+j, y, t)
+				}
+			}
+		}
+	}
+}
+
+// LayerNode is one of the kinds of layer that can be the child of a Map or a
+// Group. It is either a *Layer, *ObjectGroup, *ImageLayer or *Group.
+type LayerNode interface {
+	// LayerIndex returns the position of the layer among the layers of its map
+	// or group
+	LayerIndex() int
+	layerOffset() int64
+}
+
+// LayerIndex returns the position of the layer among the layers of its map or
+// group
+func (l *Layer) LayerIndex() int { return l.Index }
+
+func (l *Layer) layerOffset() int64 { return l.offset }
+
+// LayerIndex returns the position of the layer among the layers of its map or
+// group
+func (o *ObjectGroup) LayerIndex() int { return o.Index }
+
+func (o *ObjectGroup) layerOffset() int64 { return o.offset }
+
+// LayerIndex returns the position of the layer among the layers of its map or
+// group
+func (i *ImageLayer) LayerIndex() int { return i.Index }
+
+func (i *ImageLayer) layerOffset() int64 { return i.offset }
+
+// LayerIndex returns the position of the layer among the layers of its map or
+// group
+func (g *Group) LayerIndex() int { return g.Index }
+
+func (g *Group) layerOffset() int64 { return g.offset }
+
+// layerNodes returns all of the layers, sorted so the layers that come first
+// are the ones that are drawn first
+func layerNodes(layers []Layer, objectGroups []ObjectGroup, imageLayers []ImageLayer, groups []Group) []LayerNode {
+	nodes := make([]LayerNode, 0, len(layers)+len(objectGroups)+len(imageLayers)+len(groups))
+	for i := range layers {
+		nodes = append(nodes, &layers[i])
+	}
+	for i := range objectGroups {
+		nodes = append(nodes, &objectGroups[i])
+	}
+	for i := range imageLayers {
+		nodes = append(nodes, &imageLayers[i])
+	}
+	for i := range groups {
+		nodes = append(nodes, &groups[i])
+	}
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return nodes[i].LayerIndex() < nodes[j].LayerIndex()
+	})
+	return nodes
+}
+
+// indexLayers sets the Index of each layer from where it was found in the file
+func indexLayers(layers []Layer, objectGroups []ObjectGroup, imageLayers []ImageLayer, groups []Group) {
+	nodes := layerNodes(layers, objectGroups, imageLayers, groups)
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return nodes[i].layerOffset() < nodes[j].layerOffset()
+	})
+	for i, n := range nodes {
+		switch l := n.(type) {
+		case *Layer:
+			l.Index = i
+		case *ObjectGroup:
+			l.Index = i
+		case *ImageLayer:
+			l.Index = i
+		case *Group:
+			l.Index = i
+		}
+	}
+}
+
+// LayerByID returns the layer of the map with the ID, searching inside
+// groups. It returns false if there is no layer with the ID, or the ID is 0.
+func (m *Map) LayerByID(id int) (LayerNode, bool) {
+	if id == 0 {
+		return nil, false
+	}
+	var found LayerNode
+	walkLayers(m.LayerNodes(), Effective{}, func(n LayerNode, _ Effective) bool {
+		if layerAttrsOf(n).id == id {
+			found = n
+			return false
+		}
+		return true
+	})
+	return found, found != nil
+}
+
+// Effective is how a layer is drawn once the attributes of the groups it's in
+// are combined with its own. Opacities, parallax factors and tint colors are
+// multiplied, offsets are added, and the layer is only visible if it and all of
+// its groups are.
+type Effective struct {
+	// Opacity is the opacity of the layer from 0 to 1
+	Opacity float64
+	// Visible is whether the layer is shown
+	Visible bool
+	// OffsetX and OffsetY are the rendering offset of the layer in pixels
+	OffsetX, OffsetY float64
+	// ParallaxX and ParallaxY are the parallax scrolling factors of the layer
+	ParallaxX, ParallaxY float64
+	// Tint is the color the tiles and images of the layer are multiplied with.
+	// It is opaque white if neither the layer nor its groups are tinted.
+	Tint color.NRGBA
+}
+
+// Effective returns how the layer n of the map is drawn, taking into account
+// the groups it's in. Tint colors that can't be parsed are ignored. It returns
+// false if n isn't one of the map's layers.
+func (m *Map) Effective(n LayerNode) (Effective, bool) {
+	var e Effective
+	found := false
+	root := Effective{Opacity: 1, Visible: true, ParallaxX: 1, ParallaxY: 1, Tint: white}
+	walkLayers(m.LayerNodes(), root, func(l LayerNode, le Effective) bool {
+		if l == n {
+			e, found = le, true
+			return false
+		}
+		return true
+	})
+	return e, found
+}
+
+// ParallaxOffset returns where the layer n is drawn, in pixels, when the view
+// of the map is centered on cx, cy. It's the layer's effective offset, moved
+// by how far the view is from the map's parallax origin times one minus the
+// layer's parallax factor, which is how Tiled scrolls parallax layers. It
+// returns false if n isn't one of the map's layers.
+func (m *Map) ParallaxOffset(n LayerNode, cx, cy float64) (float64, float64, bool) {
+	e, ok := m.Effective(n)
+	if !ok {
+		return 0, 0, false
+	}
+	x := e.OffsetX + (cx-m.ParallaxOriginX)*(1-e.ParallaxX)
+	y := e.OffsetY + (cy-m.ParallaxOriginY)*(1-e.ParallaxY)
+	return x, y, true
+}
+
+var white = color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+
+// combine returns the effective attributes of a layer inside a group with the
+// effective attributes e
+func (e Effective) combine(a layerAttrs) Effective {
+	e.Opacity *= a.opacity
+	e.Visible = e.Visible && a.visible != 0
+	e.OffsetX += a.offsetX
+	e.OffsetY += a.offsetY
+	e.ParallaxX *= a.parallaxX
+	e.ParallaxY *= a.parallaxY
+	if a.tint != "" {
+		if t, err := ParseColor(a.tint); err == nil {
+			e.Tint = MultiplyColor(e.Tint, t)
+		}
+	}
+	return e
+}
+
+// MultiplyColor returns the colors multiplied channel by channel, the way
+// Tiled combines tint colors
+func MultiplyColor(a, b color.NRGBA) color.NRGBA {
+	mul := func(x, y uint8) uint8 {
+		return uint8((uint16(x)*uint16(y) + 127) / 255)
+	}
+	return color.NRGBA{R: mul(a.R, b.R), G: mul(a.G, b.G), B: mul(a.B, b.B), A: mul(a.A, b.A)}
+}
+
+// walkLayers calls fn for each layer in the order they are drawn, going into
+// groups after calling fn for the group, until fn returns false. parent is the
+// effective attributes of the group holding the layers.
+func walkLayers(nodes []LayerNode, parent Effective, fn func(LayerNode, Effective) bool) bool {
+	for _, n := range nodes {
+		e := parent.combine(layerAttrsOf(n))
+		if !fn(n, e) {
+			return false
+		}
+		if g, ok := n.(*Group); ok && !walkLayers(g.LayerNodes(), e, fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// layerAttrs are the attributes every kind of layer has
+type layerAttrs struct {
+	id                   int
+	opacity              float64
+	visible              int
+	offsetX, offsetY     float64
+	parallaxX, parallaxY float64
+	tint                 string
+}
+
+func layerAttrsOf(n LayerNode) layerAttrs {
+	switch l := n.(type) {
+	case *Layer:
+		return layerAttrs{l.ID, l.Opacity, l.Visible, l.OffsetX, l.OffsetY, l.ParallaxX, l.ParallaxY, l.TintColor}
+	case *ObjectGroup:
+		return layerAttrs{l.ID, l.Opacity, l.Visible, l.OffsetX, l.OffsetY, l.ParallaxX, l.ParallaxY, l.TintColor}
+	case *ImageLayer:
+		return layerAttrs{l.ID, l.Opacity, l.Visible, l.OffsetX, l.OffsetY, l.ParallaxX, l.ParallaxY, l.TintColor}
+	case *Group:
+		return layerAttrs{l.ID, l.Opacity, l.Visible, l.OffsetX, l.OffsetY, l.ParallaxX, l.ParallaxY, l.TintColor}
+	}
+	return layerAttrs{}
+}
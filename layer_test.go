@@ -0,0 +1,404 @@
+package tmx
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"os"
+	"strings"
+	"testing"
+)
+
+func layerNodeNames(nodes []LayerNode) []string {
+	names := make([]string, len(nodes))
+	for i, n := range nodes {
+		switch l := n.(type) {
+		case *Layer:
+			names[i] = l.Name
+		case *ObjectGroup:
+			names[i] = l.Name
+		case *ImageLayer:
+			names[i] = l.Name
+		case *Group:
+			names[i] = l.Name
+		}
+	}
+	return names
+}
+
+func checkLayerOrder(t *testing.T, m Map) {
+	exp := []string{"Ground", "Spawns", "Walls", "Decoration", "Clouds"}
+	got := layerNodeNames(m.LayerNodes())
+	if len(got) != len(exp) {
+		t.Errorf("Wrong number of layers\nWanted: %v\nGot: %v", exp, got)
+		return
+	}
+	for i := range exp {
+		if got[i] != exp[i] {
+			t.Errorf("Layers are not in file order\nWanted: %v\nGot: %v", exp, got)
+			return
+		}
+	}
+	exp = []string{"Sky", "Props", "Lights"}
+	got = layerNodeNames(m.Groups[0].LayerNodes())
+	for i := range exp {
+		if got[i] != exp[i] {
+			t.Errorf("Group layers are not in file order\nWanted: %v\nGot: %v", exp, got)
+			return
+		}
+	}
+}
+
+func TestLayerOrder(t *testing.T) {
+	m, err := ParseFS(os.DirFS("testData"), "layerOrder.tmx")
+	if err != nil {
+		t.Errorf("Unable to parse layerOrder.tmx. Error was: %v", err)
+		return
+	}
+	checkLayerOrder(t, m)
+	if m.Layers[1].Index != 2 {
+		t.Errorf("Layer index not set\nWanted: %v\nGot: %v", 2, m.Layers[1].Index)
+	}
+	var buf bytes.Buffer
+	if err = Encode(&buf, m); err != nil {
+		t.Errorf("Unable to encode layerOrder.tmx. Error was: %v", err)
+		return
+	}
+	m, err = Parse(&buf)
+	if err != nil {
+		t.Errorf("Unable to parse encoded layerOrder.tmx. Error was: %v", err)
+		return
+	}
+	checkLayerOrder(t, m)
+}
+
+func TestLayerOrderJSON(t *testing.T) {
+	m, err := ParseFS(os.DirFS("testData"), "jsonMap.tmj")
+	if err != nil {
+		t.Errorf("Unable to parse jsonMap.tmj. Error was: %v", err)
+		return
+	}
+	exp := []string{"Tile Layer 1", "Object Layer 1", "Group 1"}
+	got := layerNodeNames(m.LayerNodes())
+	for i := range exp {
+		if got[i] != exp[i] {
+			t.Errorf("Layers are not in file order\nWanted: %v\nGot: %v", exp, got)
+			return
+		}
+	}
+}
+
+func TestLayerInfinite(t *testing.T) {
+	m, err := ParseFS(os.DirFS("testData"), "infiniteData.tmx")
+	if err != nil {
+		t.Errorf("Unable to parse infiniteData.tmx. Error was: %v", err)
+		return
+	}
+	if m.Infinite != 1 {
+		t.Errorf("Infinite was not parsed\nWanted: %v\nGot: %v", 1, m.Infinite)
+	}
+	l := &m.Layers[0]
+	tests := []struct {
+		x, y int
+		gid  uint32
+		ok   bool
+	}{
+		{-4, -2, 1, true},
+		{-3, -1, 4, true},
+		{2, 0, 5, true},
+		{3, 1, 6, true},
+		{0, 0, 0, false},
+		{-5, -2, 0, false},
+		{4, 1, 0, false},
+	}
+	for _, test := range tests {
+		tile, ok := l.TileAt(test.x, test.y)
+		if ok != test.ok || tile.GID != test.gid {
+			t.Errorf("Wrong tile at %v,%v\nWanted: %v %v\nGot: %v %v", test.x, test.y, test.gid, test.ok, tile.GID, ok)
+		}
+	}
+	if b := l.Bounds(); b != image.Rect(-4, -2, 4, 2) {
+		t.Errorf("Wrong layer bounds\nWanted: %v\nGot: %v", image.Rect(-4, -2, 4, 2), b)
+	}
+	tiles, b := l.Flatten()
+	if len(tiles) != 32 {
+		t.Errorf("Wrong number of flattened tiles\nWanted: %v\nGot: %v", 32, len(tiles))
+		return
+	}
+	exp := []uint32{
+		1, 2, 0, 0, 0, 0, 0, 0,
+		3, 4, 0, 0, 0, 0, 0, 0,
+		0, 0, 0, 0, 0, 0, 5, 0,
+		0, 0, 0, 0, 0, 0, 0, 6,
+	}
+	for i, e := range exp {
+		if tiles[i].GID != e {
+			t.Errorf("Wrong flattened tile at %v,%v\nWanted: %v\nGot: %v", i%b.Dx()+b.Min.X, i/b.Dx()+b.Min.Y, e, tiles[i].GID)
+			return
+		}
+	}
+}
+
+func TestLayerFixed(t *testing.T) {
+	m, err := ParseFS(os.DirFS("testData"), "zlibData.tmx")
+	if err != nil {
+		t.Errorf("Unable to parse zlibData.tmx. Error was: %v", err)
+		return
+	}
+	l := &m.Layers[0]
+	if m.Infinite != 0 {
+		t.Errorf("Infinite was not parsed\nWanted: %v\nGot: %v", 0, m.Infinite)
+	}
+	if b := l.Bounds(); b != image.Rect(0, 0, 3, 3) {
+		t.Errorf("Wrong layer bounds\nWanted: %v\nGot: %v", image.Rect(0, 0, 3, 3), b)
+	}
+	if tile, ok := l.TileAt(1, 2); !ok || tile.GID != testDataExpected[7] {
+		t.Errorf("Wrong tile at 1,2\nWanted: %v\nGot: %v", testDataExpected[7], tile.GID)
+	}
+	if _, ok := l.TileAt(3, 0); ok {
+		t.Errorf("Found a tile outside of the layer")
+	}
+	tiles, _ := l.Flatten()
+	for i, e := range testDataExpected {
+		if tiles[i].GID != e {
+			t.Errorf("Wrong flattened tile %v\nWanted: %v\nGot: %v", i, e, tiles[i].GID)
+			return
+		}
+	}
+}
+
+func farChunks() Layer {
+	far := 1 << 20
+	return Layer{Data: []Data{{Chunks: []Chunk{
+		{X: -2, Y: -2, Width: 2, Height: 2, Tiles: []TileData{{GID: 1}, {GID: 2}, {GID: 3}, {GID: 4}}},
+		{X: far, Y: far, Width: 2, Height: 2, Tiles: []TileData{{GID: 5}, {}, {}, {GID: 6}}},
+	}}}}
+}
+
+func TestLayerIndexChunks(t *testing.T) {
+	far := 1 << 20
+	tests := []struct {
+		x, y int
+		gid  uint32
+		ok   bool
+	}{
+		{-2, -2, 1, true},
+		{-1, -1, 4, true},
+		{far, far, 5, true},
+		{far + 1, far + 1, 6, true},
+		{0, 0, 0, false},
+		{far + 2, far, 0, false},
+	}
+	l := farChunks()
+	for _, indexed := range []bool{false, true} {
+		if indexed {
+			l.IndexChunks()
+			if l.chunks == nil {
+				t.Fatalf("Aligned chunks were not indexed")
+			}
+		}
+		for _, test := range tests {
+			tile, ok := l.TileAt(test.x, test.y)
+			if ok != test.ok || tile.GID != test.gid {
+				t.Errorf("Wrong tile at %v,%v (indexed %v)\nWanted: %v %v\nGot: %v %v", test.x, test.y, indexed, test.gid, test.ok, tile.GID, ok)
+			}
+		}
+	}
+	n := 0
+	l.EachChunk(func(c *Chunk) {
+		n++
+	})
+	if n != 2 {
+		t.Errorf("Wrong number of chunks\nWanted: %v\nGot: %v", 2, n)
+	}
+
+	l.Data[0].Chunks[1].X++
+	l.IndexChunks()
+	if l.chunks != nil {
+		t.Errorf("Chunks off the grid were indexed")
+	}
+	if tile, ok := l.TileAt(far+1, far); !ok || tile.GID != 5 {
+		t.Errorf("Wrong tile at %v,%v\nWanted: %v\nGot: %v", far+1, far, 5, tile.GID)
+	}
+}
+
+func TestLayerEachTile(t *testing.T) {
+	m, err := ParseFS(os.DirFS("testData"), "infiniteData.tmx")
+	if err != nil {
+		t.Fatalf("Unable to parse infiniteData.tmx. Error was: %v", err)
+	}
+	exp := map[string][]uint32{
+		"":           []uint32{1, 2, 3, 4, 5, 6},
+		"right-down": []uint32{1, 2, 3, 4, 5, 6},
+		"right-up":   []uint32{6, 5, 3, 4, 1, 2},
+		"left-down":  []uint32{2, 1, 4, 3, 5, 6},
+		"left-up":    []uint32{6, 5, 4, 3, 2, 1},
+	}
+	for order, gids := range exp {
+		var got []uint32
+		m.Layers[0].EachTile(order, func(x, y int, td TileData) {
+			if at, _ := m.Layers[0].TileAt(x, y); at != td {
+				t.Errorf("Tile visited at %v,%v does not match TileAt\nWanted: %v\nGot: %v", x, y, at, td)
+			}
+			got = append(got, td.GID)
+		})
+		if len(got) != len(gids) {
+			t.Errorf("Wrong tiles visited in %q order\nWanted: %v\nGot: %v", order, gids, got)
+			continue
+		}
+		for i := range gids {
+			if got[i] != gids[i] {
+				t.Errorf("Wrong tiles visited in %q order\nWanted: %v\nGot: %v", order, gids, got)
+				break
+			}
+		}
+	}
+}
+
+func TestLayerAttributes(t *testing.T) {
+	m, err := ParseFS(os.DirFS("testData"), "layerAttributes.tmx")
+	if err != nil {
+		t.Fatalf("Unable to parse layerAttributes.tmx. Error was: %v", err)
+	}
+	checkLayerAttributes(t, m)
+}
+
+func TestLayerAttributesJSON(t *testing.T) {
+	m, err := ParseJSON(strings.NewReader(`{"class":"Level","orientation":"orthogonal","width":2,"height":2,"tilewidth":16,"tileheight":16,
+ "parallaxoriginx":100,"parallaxoriginy":50,"compressionlevel":6,"nextlayerid":6,"nextobjectid":1,
+ "layers":[
+  {"type":"tilelayer","id":1,"name":"Ground","class":"Terrain","width":2,"height":2,"locked":true,"data":[0,0,0,0]},
+  {"type":"group","id":2,"name":"Background","offsetx":10,"opacity":0.5,"parallaxx":0.5,"parallaxy":0.8,"tintcolor":"#ff00ff","layers":[
+   {"type":"imagelayer","id":3,"name":"Sky","offsetx":5,"parallaxx":0.5,"tintcolor":"#80ffff00","repeatx":true,"repeaty":true,"image":"sky.png"},
+   {"type":"objectgroup","id":4,"name":"Clouds","visible":false,"objects":[]}
+  ]},
+  {"type":"objectgroup","id":5,"name":"Things","objects":[]}
+ ]}`))
+	if err != nil {
+		t.Fatalf("Unable to parse JSON map. Error was: %v", err)
+	}
+	checkLayerAttributes(t, m)
+}
+
+func checkLayerAttributes(t *testing.T, m Map) {
+	t.Helper()
+	if m.Class != "Level" || m.ParallaxOriginX != 100 || m.ParallaxOriginY != 50 || m.CompressionLevel != 6 || m.NextLayerID != 6 {
+		t.Errorf("Map attributes were incorrect\nGot: %v %v %v %v %v", m.Class, m.ParallaxOriginX, m.ParallaxOriginY, m.CompressionLevel, m.NextLayerID)
+	}
+	l := m.Layers[0]
+	if l.ID != 1 || l.Class != "Terrain" || l.Locked != 1 || l.ParallaxX != 1 || l.ParallaxY != 1 || l.TintColor != "" {
+		t.Errorf("Layer attributes were incorrect\nGot: %v %v %v %v %v %v", l.ID, l.Class, l.Locked, l.ParallaxX, l.ParallaxY, l.TintColor)
+	}
+	g := m.Groups[0]
+	if g.ID != 2 || g.ParallaxX != 0.5 || g.ParallaxY != 0.8 || g.TintColor != "#ff00ff" {
+		t.Errorf("Group attributes were incorrect\nGot: %v %v %v %v", g.ID, g.ParallaxX, g.ParallaxY, g.TintColor)
+	}
+	il := g.ImageLayers[0]
+	if il.ID != 3 || il.RepeatX != 1 || il.RepeatY != 1 || il.TintColor != "#80ffff00" {
+		t.Errorf("Image layer attributes were incorrect\nGot: %v %v %v %v", il.ID, il.RepeatX, il.RepeatY, il.TintColor)
+	}
+	if og := m.ObjectGroups[0]; og.ID != 5 || og.ParallaxX != 1 {
+		t.Errorf("Object group attributes were incorrect\nGot: %v %v", og.ID, og.ParallaxX)
+	}
+
+	for id, name := range map[int]string{1: "Ground", 3: "Sky", 4: "Clouds", 5: "Things"} {
+		n, ok := m.LayerByID(id)
+		if !ok {
+			t.Errorf("Layer %v wasn't found", id)
+			continue
+		}
+		got := ""
+		switch l := n.(type) {
+		case *Layer:
+			got = l.Name
+		case *ImageLayer:
+			got = l.Name
+		case *ObjectGroup:
+			got = l.Name
+		}
+		if got != name {
+			t.Errorf("Layer %v was incorrect\nWanted: %v\nGot: %v", id, name, got)
+		}
+	}
+	if _, ok := m.LayerByID(7); ok {
+		t.Errorf("Found a layer with an ID that isn't used")
+	}
+
+	sky, _ := m.LayerByID(3)
+	e, ok := m.Effective(sky)
+	if !ok {
+		t.Fatalf("Effective attributes of the sky weren't found")
+	}
+	exp := Effective{
+		Opacity:   0.5,
+		Visible:   true,
+		OffsetX:   15,
+		ParallaxX: 0.25,
+		ParallaxY: 0.8,
+		Tint:      color.NRGBA{R: 0xff, G: 0, B: 0, A: 0x80},
+	}
+	if e != exp {
+		t.Errorf("Effective attributes of the sky were incorrect\nWanted: %+v\nGot: %+v", exp, e)
+	}
+	clouds, _ := m.LayerByID(4)
+	if e, _ := m.Effective(clouds); e.Visible || e.Tint != (color.NRGBA{R: 0xff, B: 0xff, A: 0xff}) {
+		t.Errorf("Effective attributes of the clouds were incorrect\nGot: %+v", e)
+	}
+	x, y, _ := m.ParallaxOffset(sky, 300, 50)
+	if x != 165 || y != 0 {
+		t.Errorf("Parallax offset of the sky was incorrect\nWanted: %v, %v\nGot: %v, %v", 165, 0, x, y)
+	}
+	if _, ok := m.Effective(&Layer{}); ok {
+		t.Errorf("Found effective attributes of a layer that isn't in the map")
+	}
+}
+
+func TestLayerAttributesDefaults(t *testing.T) {
+	m, err := ParseFS(os.DirFS("testData"), "csvData.tmx")
+	if err != nil {
+		t.Fatalf("Unable to parse csvData.tmx. Error was: %v", err)
+	}
+	if m.CompressionLevel != -1 {
+		t.Errorf("Default compression level was incorrect\nWanted: %v\nGot: %v", -1, m.CompressionLevel)
+	}
+	if l := m.Layers[0]; l.ParallaxX != 1 || l.ParallaxY != 1 {
+		t.Errorf("Default parallax factor was incorrect\nWanted: %v, %v\nGot: %v, %v", 1, 1, l.ParallaxX, l.ParallaxY)
+	}
+}
+
+func TestEncodeLayerAttributes(t *testing.T) {
+	m, m2, _ := roundTrip(t, "layerAttributes.tmx")
+	checkLayerAttributes(t, m2)
+	if m2.Groups[0].ImageLayers[0].RepeatX != m.Groups[0].ImageLayers[0].RepeatX {
+		t.Errorf("Encoded image layer repeat was incorrect")
+	}
+}
+
+func TestLayerEachTileFarChunks(t *testing.T) {
+	l := farChunks()
+	// A chunk beside the first, so rows are visited across both chunks
+	l.Data[0].Chunks = append(l.Data[0].Chunks, Chunk{X: 0, Y: -2, Width: 2, Height: 2, Tiles: []TileData{{GID: 7}, {}, {GID: 8}, {}}})
+	exp := map[string][]uint32{
+		"right-down": []uint32{1, 2, 7, 3, 4, 8, 5, 6},
+		"left-up":    []uint32{6, 5, 8, 4, 3, 7, 2, 1},
+	}
+	for order, gids := range exp {
+		var got []uint32
+		l.EachTile(order, func(x, y int, td TileData) {
+			if at, _ := l.TileAt(x, y); at != td {
+				t.Errorf("Tile visited at %v,%v does not match TileAt\nWanted: %v\nGot: %v", x, y, at, td)
+			}
+			got = append(got, td.GID)
+		})
+		if len(got) != len(gids) {
+			t.Errorf("Wrong tiles visited in %q order\nWanted: %v\nGot: %v", order, gids, got)
+			continue
+		}
+		for i := range gids {
+			if got[i] != gids[i] {
+				t.Errorf("Wrong tiles visited in %q order\nWanted: %v\nGot: %v", order, gids, got)
+				break
+			}
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package tmx
 
-import "encoding/xml"
+import (
+	"encoding/xml"
+	"strconv"
+)
 
 // Map is the root element of a TMX map
 type Map struct {
@@ -26,6 +29,9 @@ type Map struct {
 	TileWidth int `xml:"tilewidth,attr"`
 	// TileHeight is the height of each tile in pixels
 	TileHeight int `xml:"tileheight,attr"`
+	// Infinite is whether the map is infinite (1) or has a fixed size (0). The
+	// tile layers of infinite maps store their tiles in chunks.
+	Infinite int `xml:"infinite,attr,omitempty"`
 	// HexSideLength determines the width or height (depending on the staggered
 	// axis) of the tile’s edge, in pixels. Only for hexagonal maps.
 	HexSideLength int `xml:"hexsidelength,attr,omitempty"`
@@ -37,10 +43,24 @@ type Map struct {
 	StaggerIndex string `xml:"staggerindex,attr,omitempty"`
 	// BackgroundColor is the background color of the map. Is of the form #AARRGGBB
 	BackgroundColor string `xml:"backgroundcolor,attr,omitempty"`
+	// Class is the class of the map
+	Class string `xml:"class,attr,omitempty"`
+	// ParallaxOriginX is the x coordinate of the parallax origin in pixels. It
+	// is where layers with any parallax factor line up.
+	ParallaxOriginX float64 `xml:"parallaxoriginx,attr,omitempty"`
+	// ParallaxOriginY is the y coordinate of the parallax origin in pixels
+	ParallaxOriginY float64 `xml:"parallaxoriginy,attr,omitempty"`
+	// CompressionLevel is the compression level Tiled uses for tile layer
+	// data. Defaults to -1, the default level of the compression. It's kept
+	// and written back as it is, but Encode ignores it and compresses tile
+	// layer data at the default level of its compression.
+	CompressionLevel int `xml:"compressionlevel,attr"`
+	// NextLayerID stores the next layer id available for new layers.
+	NextLayerID int `xml:"nextlayerid,attr,omitempty"`
 	// NextObjectID stores the next object id available for new objects.
 	NextObjectID int `xml:"nextobjectid,attr,omitempty"`
 	// Properties are the properties of the map
-	Properties []Property `xml:"properties,omitempty>property"`
+	Properties Properties `xml:"properties>property"`
 	// Tilesets are the tilesets of the map
 	Tilesets []Tileset `xml:"tileset"`
 	// Layers are the layers of the map
@@ -51,17 +71,73 @@ type Map struct {
 	ImageLayers []ImageLayer `xml:"imagelayer"`
 	// Groups are the groups of the map
 	Groups []Group `xml:"group"`
+
+	// Warnings are the problems found by Validate when the map was loaded
+	// by a Loader that isn't Strict
+	Warnings []error `xml:"-"`
+
+	// gids is the lookup table used by TileForGID, built by IndexTilesets
+	gids *gidTable
 }
 
 // UnmarshalXML implements the encoding/xml Unmarshaler interface
 func (m *Map) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 	type maph Map
 	ma := maph{
-		RenderOrder: "right-down",
+		RenderOrder:      "right-down",
+		CompressionLevel: -1,
 	}
+	line := currentLine(d)
 	if err := d.DecodeElement(&ma, &start); err != nil {
-		return err
+		return errorAt(err, "map", line)
 	}
 	*m = (Map)(ma)
+	indexLayers(m.Layers, m.ObjectGroups, m.ImageLayers, m.Groups)
 	return nil
 }
+
+// LayerNodes returns the top level layers of the map in the order they are
+// drawn
+func (m *Map) LayerNodes() []LayerNode {
+	return layerNodes(m.Layers, m.ObjectGroups, m.ImageLayers, m.Groups)
+}
+
+// MarshalXML implements the encoding/xml Marshaler interface
+func (m Map) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	a := attrs{}
+	a.str("version", m.Version, "")
+	a.str("tiledversion", m.TiledVersion, "")
+	a.str("class", m.Class, "")
+	a.add("orientation", m.Orientation)
+	a.str("renderorder", m.RenderOrder, "")
+	a.add("width", strconv.Itoa(m.Width))
+	a.add("height", strconv.Itoa(m.Height))
+	a.add("tilewidth", strconv.Itoa(m.TileWidth))
+	a.add("tileheight", strconv.Itoa(m.TileHeight))
+	a.int("infinite", m.Infinite, 0)
+	a.int("hexsidelength", m.HexSideLength, 0)
+	a.str("staggeraxis", m.StaggerAxis, "")
+	a.str("staggerindex", m.StaggerIndex, "")
+	a.float("parallaxoriginx", m.ParallaxOriginX, 0)
+	a.float("parallaxoriginy", m.ParallaxOriginY, 0)
+	a.str("backgroundcolor", m.BackgroundColor, "")
+	a.int("compressionlevel", m.CompressionLevel, -1)
+	a.int("nextlayerid", m.NextLayerID, 0)
+	a.int("nextobjectid", m.NextObjectID, 0)
+	start, err := encodeStart(e, start, a)
+	if err != nil {
+		return err
+	}
+	if err = encodeProperties(e, m.Properties); err != nil {
+		return err
+	}
+	for _, t := range m.Tilesets {
+		if err = e.EncodeElement(t, startElement("tileset")); err != nil {
+			return err
+		}
+	}
+	if err = encodeLayers(e, m.LayerNodes()); err != nil {
+		return err
+	}
+	return e.EncodeToken(start.End())
+}
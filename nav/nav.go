@@ -0,0 +1,256 @@
+// Package nav builds navigation grids from the tile layers of Tiled maps and
+// finds paths through them with A*.
+package nav
+
+import (
+	"container/heap"
+	"image"
+	"math"
+
+	"github.com/Noofbiz/tmx"
+)
+
+// CostFunc returns the cost of entering a tile, and whether the tile can be
+// walked on at all. info is the tile's tileset and metadata, and t is the tile
+// as placed in the layer.
+type CostFunc func(info tmx.TileInfo, t tmx.TileData) (cost float64, walkable bool)
+
+// PropertyCost returns a CostFunc that reads the tile custom property named
+// walkable, a bool, and the property named cost, an int or float. Tiles are
+// walkable with a cost of 1 unless their properties say otherwise. Either name
+// can be empty to skip that property.
+func PropertyCost(walkable, cost string) CostFunc {
+	return func(info tmx.TileInfo, t tmx.TileData) (float64, bool) {
+		c := 1.0
+		if info.Tile == nil {
+			return c, true
+		}
+		if p, ok := info.Tile.Properties.Get(walkable); walkable != "" && ok {
+			if b, err := p.Bool(); err == nil && !b {
+				return 0, false
+			}
+		}
+		if p, ok := info.Tile.Properties.Get(cost); cost != "" && ok {
+			if f, err := p.Float(); err == nil {
+				c = f
+			}
+		}
+		return c, true
+	}
+}
+
+// Grid is the cost of walking on each tile of a map, combined from one or more
+// of its layers. A cell is walkable if it has a tile in at least one of the
+// layers and every tile in it is walkable. Its cost is the highest cost of its
+// tiles.
+//
+// Costs are worked out the first time a cell is needed and then kept, so large
+// and infinite maps only read the chunks a search reaches. Call Invalidate
+// after changing the layers.
+type Grid struct {
+	// Diagonal allows moving diagonally on orthogonal and isometric maps, as
+	// long as both cells next to the diagonal are walkable. Diagonal steps cost
+	// √2 times the cost of the cell entered.
+	Diagonal bool
+	// MinCost is the lowest cost the CostFunc returns. It keeps the A*
+	// heuristic from overestimating, so paths are the cheapest. Defaults to 1.
+	MinCost float64
+
+	m      *tmx.Map
+	layers []*tmx.Layer
+	cost   CostFunc
+	cells  map[image.Point]cell
+}
+
+type cell struct {
+	cost     float64
+	walkable bool
+}
+
+// NewGrid returns a Grid for the layers of the map, with the cost of each tile
+// given by cost
+func NewGrid(m *tmx.Map, cost CostFunc, layers ...*tmx.Layer) *Grid {
+	return &Grid{
+		MinCost: 1,
+		m:       m,
+		layers:  layers,
+		cost:    cost,
+		cells:   make(map[image.Point]cell),
+	}
+}
+
+// Cost returns the cost of entering the cell at x, y, and whether it can be
+// walked on
+func (g *Grid) Cost(x, y int) (float64, bool) {
+	c := g.cell(image.Pt(x, y))
+	return c.cost, c.walkable
+}
+
+// Invalidate forgets the costs worked out so far, so changes to the layers are
+// seen
+func (g *Grid) Invalidate() {
+	g.cells = make(map[image.Point]cell)
+}
+
+func (g *Grid) cell(p image.Point) cell {
+	if c, ok := g.cells[p]; ok {
+		return c
+	}
+	c := cell{}
+	for _, l := range g.layers {
+		t, ok := l.TileAt(p.X, p.Y)
+		if !ok || t.GID == 0 {
+			continue
+		}
+		info, ok := g.m.TileForGID(t.GID)
+		if !ok {
+			continue
+		}
+		cost, walkable := g.cost(info, t)
+		if !walkable {
+			c = cell{}
+			break
+		}
+		c.walkable = true
+		if cost > c.cost {
+			c.cost = cost
+		}
+	}
+	g.cells[p] = c
+	return c
+}
+
+// step is a move to a neighbouring cell, with the cost of the move as a
+// multiple of the cost of the cell entered
+type step struct {
+	to     image.Point
+	factor float64
+}
+
+// neighbors returns the walkable cells next to p, following the adjacency
+// rules of the map's orientation
+func (g *Grid) neighbors(p image.Point) []step {
+	var steps []step
+	for _, n := range g.m.Neighbors(p.X, p.Y) {
+		if g.cell(n).walkable {
+			steps = append(steps, step{to: n, factor: 1})
+		}
+	}
+	o := g.m.Orientation
+	if !g.Diagonal || (o != "" && o != "orthogonal" && o != "isometric") {
+		return steps
+	}
+	for _, d := range []image.Point{{1, -1}, {1, 1}, {-1, 1}, {-1, -1}} {
+		n := p.Add(d)
+		if g.m.Infinite == 0 && !n.In(image.Rect(0, 0, g.m.Width, g.m.Height)) {
+			continue
+		}
+		// Don't cut corners
+		if !g.cell(n).walkable || !g.cell(image.Pt(n.X, p.Y)).walkable || !g.cell(image.Pt(p.X, n.Y)).walkable {
+			continue
+		}
+		steps = append(steps, step{to: n, factor: math.Sqrt2})
+	}
+	return steps
+}
+
+// Path returns the cheapest path from the cell from to the cell to, including
+// both, and its cost. It returns false if either cell isn't walkable or there
+// is no path between them.
+func (g *Grid) Path(from, to image.Point) ([]image.Point, float64, bool) {
+	if !g.cell(from).walkable || !g.cell(to).walkable {
+		return nil, 0, false
+	}
+	h := g.heuristic(to)
+	open := &queue{}
+	heap.Push(open, &node{p: from, f: h(from)})
+	costs := map[image.Point]float64{from: 0}
+	came := make(map[image.Point]image.Point)
+	closed := make(map[image.Point]bool)
+	for open.Len() > 0 {
+		n := heap.Pop(open).(*node)
+		if n.p == to {
+			return reconstruct(came, from, to), costs[to], true
+		}
+		if closed[n.p] {
+			continue
+		}
+		closed[n.p] = true
+		for _, s := range g.neighbors(n.p) {
+			if closed[s.to] {
+				continue
+			}
+			c := costs[n.p] + g.cell(s.to).cost*s.factor
+			if old, ok := costs[s.to]; ok && old <= c {
+				continue
+			}
+			costs[s.to] = c
+			came[s.to] = n.p
+			heap.Push(open, &node{p: s.to, f: c + h(s.to)})
+		}
+	}
+	return nil, 0, false
+}
+
+// heuristic returns the A* heuristic for reaching goal. No step moves further
+// than the longest step between neighbouring cells, so the straight line
+// distance between cell centers divided by that length is never more than the
+// number of steps left, whatever the orientation.
+func (g *Grid) heuristic(goal image.Point) func(image.Point) float64 {
+	longest := 0.0
+	// Measure the steps of cells in both parities of staggered rows and columns
+	free := *g.m
+	free.Infinite = 1
+	for _, p := range []image.Point{{0, 0}, {1, 1}} {
+		px, py := free.TileToPixel(p.X, p.Y)
+		ns := free.Neighbors(p.X, p.Y)
+		if g.Diagonal {
+			ns = append(ns, p.Add(image.Pt(1, 1)))
+		}
+		for _, n := range ns {
+			nx, ny := free.TileToPixel(n.X, n.Y)
+			longest = math.Max(longest, math.Hypot(nx-px, ny-py))
+		}
+	}
+	gx, gy := g.m.TileToPixel(goal.X, goal.Y)
+	return func(p image.Point) float64 {
+		if longest == 0 {
+			return 0
+		}
+		px, py := g.m.TileToPixel(p.X, p.Y)
+		return g.MinCost * math.Hypot(gx-px, gy-py) / longest
+	}
+}
+
+func reconstruct(came map[image.Point]image.Point, from, to image.Point) []image.Point {
+	path := []image.Point{to}
+	for p := to; p != from; {
+		p = came[p]
+		path = append(path, p)
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+// node is a cell waiting to be searched, with f the cost of the path to it
+// plus the heuristic
+type node struct {
+	p image.Point
+	f float64
+}
+
+// queue is a priority queue of nodes with the lowest f first
+type queue []*node
+
+func (q queue) Len() int            { return len(q) }
+func (q queue) Less(i, j int) bool  { return q[i].f < q[j].f }
+func (q queue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *queue) Push(x interface{}) { *q = append(*q, x.(*node)) }
+func (q *queue) Pop() interface{} {
+	old := *q
+	n := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return n
+}
@@ -0,0 +1,162 @@
+package nav
+
+import (
+	"image"
+	"math"
+	"testing"
+	"testing/fstest"
+
+	"github.com/Noofbiz/tmx"
+)
+
+const tileset = `<tileset firstgid="1" name="tiles" tilewidth="16" tileheight="16" tilecount="3" columns="3">
+  <image source="tiles.png" width="48" height="16"/>
+  <tile id="1">
+   <properties>
+    <property name="walkable" type="bool" value="false"/>
+   </properties>
+  </tile>
+  <tile id="2">
+   <properties>
+    <property name="cost" type="int" value="5"/>
+   </properties>
+  </tile>
+ </tileset>`
+
+func parse(t *testing.T, attrs, layers string) tmx.Map {
+	fsys := fstest.MapFS{
+		"map.tmx": &fstest.MapFile{Data: []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<map version="1.2" tilewidth="16" tileheight="16" ` + attrs + `>
+ ` + tileset + `
+ ` + layers + `
+</map>`)},
+	}
+	m, err := tmx.ParseFS(fsys, "map.tmx")
+	if err != nil {
+		t.Fatalf("Unable to parse map. Error was: %v", err)
+	}
+	return m
+}
+
+// checkPath checks that each cell of the path is next to the one before it
+func checkPath(t *testing.T, m *tmx.Map, path []image.Point, from, to image.Point) {
+	t.Helper()
+	if len(path) == 0 || path[0] != from || path[len(path)-1] != to {
+		t.Fatalf("Path does not go from %v to %v\nGot: %v", from, to, path)
+	}
+	for i := 1; i < len(path); i++ {
+		next := false
+		for _, n := range m.Neighbors(path[i-1].X, path[i-1].Y) {
+			next = next || n == path[i]
+		}
+		if !next {
+			t.Errorf("Path steps from %v to %v, which aren't neighbours\nGot: %v", path[i-1], path[i], path)
+		}
+	}
+}
+
+func TestPathLayers(t *testing.T) {
+	m := parse(t, `orientation="orthogonal" width="5" height="5"`, `<layer name="Ground" width="5" height="5"><data encoding="csv">
+1,1,1,1,1,
+1,1,1,1,1,
+1,1,1,1,1,
+1,1,1,1,1,
+1,1,1,1,1
+</data></layer>
+ <layer name="Obstacles" width="5" height="5"><data encoding="csv">
+0,0,0,0,0,
+0,2,2,2,0,
+0,0,0,2,3,
+2,2,0,2,0,
+0,0,0,0,0
+</data></layer>`)
+	g := NewGrid(&m, PropertyCost("walkable", "cost"), &m.Layers[0], &m.Layers[1])
+	if c, ok := g.Cost(4, 2); !ok || c != 5 {
+		t.Errorf("Cost of mud was incorrect\nWanted: %v\nGot: %v", 5, c)
+	}
+	if _, ok := g.Cost(1, 1); ok {
+		t.Errorf("Wall was walkable")
+	}
+	from, to := image.Pt(0, 0), image.Pt(4, 4)
+	path, cost, ok := g.Path(from, to)
+	if !ok {
+		t.Fatalf("No path found from %v to %v", from, to)
+	}
+	checkPath(t, &m, path, from, to)
+	if cost != 8 || len(path) != 9 {
+		t.Errorf("Path was not the cheapest\nWanted: cost %v\nGot: cost %v %v", 8, cost, path)
+	}
+	if path[3] != image.Pt(1, 2) {
+		t.Errorf("Path did not avoid the mud\nGot: %v", path)
+	}
+	if _, _, ok := g.Path(from, image.Pt(1, 1)); ok {
+		t.Errorf("Found a path to a wall")
+	}
+}
+
+func TestPathDiagonal(t *testing.T) {
+	m := parse(t, `orientation="orthogonal" width="3" height="3"`, `<layer name="Ground" width="3" height="3"><data encoding="csv">
+1,1,1,
+1,1,1,
+1,1,1
+</data></layer>`)
+	g := NewGrid(&m, PropertyCost("walkable", "cost"), &m.Layers[0])
+	g.Diagonal = true
+	path, cost, ok := g.Path(image.Pt(0, 0), image.Pt(2, 2))
+	if !ok || len(path) != 3 || math.Abs(cost-2*math.Sqrt2) > 1e-9 {
+		t.Errorf("Diagonal path was incorrect\nWanted: cost %v in 3 cells\nGot: cost %v %v", 2*math.Sqrt2, cost, path)
+	}
+}
+
+func TestPathHexagonal(t *testing.T) {
+	m := parse(t, `orientation="hexagonal" width="4" height="4" hexsidelength="8" staggeraxis="y" staggerindex="odd"`, `<layer name="Ground" width="4" height="4"><data encoding="csv">
+1,1,1,1,
+1,1,1,1,
+1,1,1,1,
+1,1,1,1
+</data></layer>`)
+	g := NewGrid(&m, PropertyCost("walkable", "cost"), &m.Layers[0])
+	from, to := image.Pt(0, 0), image.Pt(3, 3)
+	path, _, ok := g.Path(from, to)
+	if !ok {
+		t.Fatalf("No path found from %v to %v", from, to)
+	}
+	checkPath(t, &m, path, from, to)
+	// Find the fewest steps with a breadth first search
+	dist := map[image.Point]int{from: 0}
+	for q := []image.Point{from}; len(q) > 0; q = q[1:] {
+		for _, n := range m.Neighbors(q[0].X, q[0].Y) {
+			if _, ok := dist[n]; !ok {
+				dist[n] = dist[q[0]] + 1
+				q = append(q, n)
+			}
+		}
+	}
+	if len(path)-1 != dist[to] {
+		t.Errorf("Hexagonal path was not the shortest\nWanted: %v steps\nGot: %v", dist[to], path)
+	}
+}
+
+func TestPathInfinite(t *testing.T) {
+	m := parse(t, `orientation="orthogonal" width="2" height="2" infinite="1"`, `<layer name="Ground" width="2" height="2"><data encoding="csv">
+  <chunk x="0" y="0" width="2" height="2">1,1,1,1</chunk>
+  <chunk x="2" y="0" width="2" height="2">1,0,1,1</chunk>
+  <chunk x="100" y="100" width="1" height="1">1</chunk>
+</data></layer>`)
+	g := NewGrid(&m, PropertyCost("walkable", "cost"), &m.Layers[0])
+	from, to := image.Pt(0, 0), image.Pt(3, 1)
+	path, cost, ok := g.Path(from, to)
+	if !ok {
+		t.Fatalf("No path found across chunks from %v to %v", from, to)
+	}
+	checkPath(t, &m, path, from, to)
+	if cost != 4 {
+		t.Errorf("Path across chunks was not the cheapest\nWanted: cost %v\nGot: cost %v %v", 4, cost, path)
+	}
+	if _, _, ok := g.Path(from, image.Pt(100, 100)); ok {
+		t.Errorf("Found a path to a chunk that isn't connected")
+	}
+	if len(g.cells) > 20 {
+		t.Errorf("Grid read cells far from the search\nGot: %v cells", len(g.cells))
+	}
+}
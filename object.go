@@ -2,15 +2,22 @@ package tmx
 
 import (
 	"encoding/xml"
-	"io/ioutil"
-	"os"
-	"path"
+	"errors"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
 )
 
 // ObjectGroup is a group of objects
 type ObjectGroup struct {
 	// Name is the name of the object group
 	Name string `xml:"name,attr"`
+	// ID is the unique ID of the object group, or 0 in maps saved before
+	// Tiled 1.2
+	ID int `xml:"id,attr"`
+	// Class is the class of the object group
+	Class string `xml:"class,attr"`
 	// Color is the color used to display the objects in this group
 	Color string `xml:"color,attr"`
 	// X is the x coordinate of the object group in tiles
@@ -30,10 +37,27 @@ type ObjectGroup struct {
 	// DrawOrder is whether the objects are drawn according to the order of
 	// appearance ("index") or sorted by their y-coordinate ("topdown")
 	DrawOrder string `xml:"draworder,attr"`
+	// ParallaxX is how fast the object group scrolls horizontally compared to
+	// the view. Defaults to 1.
+	ParallaxX float64 `xml:"parallaxx,attr"`
+	// ParallaxY is how fast the object group scrolls vertically compared to
+	// the view. Defaults to 1.
+	ParallaxY float64 `xml:"parallaxy,attr"`
+	// TintColor is multiplied with the images of the tile objects in the group
+	TintColor string `xml:"tintcolor,attr"`
+	// Locked is whether the objects of the group are locked in the editor (1)
+	// or not (0)
+	Locked int `xml:"locked,attr"`
 	// Properties are the properties of the object layer
-	Properties []Property `xml:"properties>property"`
+	Properties Properties `xml:"properties>property"`
 	// Objects are the objects in the object layer
 	Objects []Object `xml:"object"`
+	// Index is the position of the layer among the layers of its map or group,
+	// in the order they appear in the file
+	Index int `xml:"-"`
+
+	// offset is where the layer was found in the file
+	offset int64
 }
 
 // Object is used to add custom information to a map, such as a spawn point
@@ -61,9 +85,13 @@ type Object struct {
 	// Template is a reference to a template file
 	Template string `xml:"template,attr"`
 	// Properties are the properties of the object
-	Properties []Property `xml:"properties>property"`
+	Properties Properties `xml:"properties>property"`
 	// Ellipses are any elliptical shapes
 	Ellipses []Ellipse `xml:"ellipse"`
+	// Points are any point shapes
+	Points []PointShape `xml:"point"`
+	// Capsules are any capsule shapes
+	Capsules []Capsule `xml:"capsule"`
 	// Polygons are any polygon shapes
 	Polygons []Polygon `xml:"polygon"`
 	// Polylines are any poly line shapes
@@ -72,24 +100,300 @@ type Object struct {
 	Text []Text `xml:"text"`
 	// Images is any image in the object
 	Images []Image `xml:"image"`
+
+	// line is where the object was found in the file
+	line int
+	// overrides are the fields the object sets itself, and ownProperties the
+	// names of its own properties. They are nil for objects that weren't read
+	// from a file.
+	overrides     map[string]bool
+	ownProperties map[string]bool
+	// template is the object of the template as it was applied, to tell which
+	// fields were changed after the object was read
+	template *Object
+}
+
+// objectFields are the attributes of an object that it can take from its
+// template, in the order they are written
+var objectFields = []string{"name", "type", "gid", "x", "y", "width", "height", "rotation", "visible"}
+
+// IsOverridden returns whether the object sets the field itself rather than
+// taking it from its template. field is the name of the attribute in the TMX
+// format, such as x or rotation, or one of properties, shape, text or image
+// for the object's child elements, where shape is any ellipse, point,
+// capsule, polygon or polyline. Attributes count as set when they're present,
+// even if their value is the default, and properties when the object has any
+// of its own. Fields changed in code so they differ from the template count as
+// set too. Objects without a template, or that weren't read from a file,
+// override every field.
+func (o *Object) IsOverridden(field string) bool {
+	if o.Template == "" || o.overrides == nil {
+		return true
+	}
+	return o.overrides[field] || o.template != nil && o.changed(field)
+}
+
+// changed returns whether the field differs from the object's template
+func (o *Object) changed(field string) bool {
+	t := o.template
+	switch field {
+	case "shape":
+		return !reflect.DeepEqual(o.Ellipses, t.Ellipses) || !reflect.DeepEqual(o.Points, t.Points) ||
+			!reflect.DeepEqual(o.Capsules, t.Capsules) || !reflect.DeepEqual(o.Polygons, t.Polygons) ||
+			!reflect.DeepEqual(o.Polylines, t.Polylines)
+	case "text":
+		return !reflect.DeepEqual(o.Text, t.Text)
+	case "image":
+		return !reflect.DeepEqual(o.Images, t.Images)
+	case "properties":
+		for _, p := range o.Properties {
+			if o.ownProperty(p) {
+				return true
+			}
+		}
+		return false
+	}
+	return o.field(field) != t.field(field)
+}
+
+// ownProperty returns whether the object sets the property p itself, because
+// it was read that way or because it differs from the template's
+func (o *Object) ownProperty(p Property) bool {
+	if o.ownProperties[p.Name] || o.template == nil {
+		return o.ownProperties[p.Name]
+	}
+	tp, ok := o.template.Properties.Get(p.Name)
+	return !ok || !reflect.DeepEqual(tp, p)
+}
+
+// setOverrides records which fields the object sets itself, given the names
+// of the attributes found on it
+func (o *Object) setOverrides(names []string) {
+	o.overrides = make(map[string]bool)
+	for _, n := range names {
+		for _, f := range objectFields {
+			if n == f {
+				o.overrides[n] = true
+			}
+		}
+	}
+	if len(o.Ellipses)+len(o.Points)+len(o.Capsules)+len(o.Polygons)+len(o.Polylines) > 0 {
+		o.overrides["shape"] = true
+	}
+	if len(o.Text) > 0 {
+		o.overrides["text"] = true
+	}
+	if len(o.Images) > 0 {
+		o.overrides["image"] = true
+	}
+	if len(o.Properties) > 0 {
+		o.overrides["properties"] = true
+	}
+	o.ownProperties = make(map[string]bool, len(o.Properties))
+	for _, p := range o.Properties {
+		o.ownProperties[p.Name] = true
+	}
+}
+
+// path returns the path segment of the object used in errors
+func (o *Object) path() string {
+	if o.ID == 0 {
+		return "object"
+	}
+	return elementPath("object", strconv.FormatUint(uint64(o.ID), 10))
 }
 
 // Ellipse is an elliptical shape
 type Ellipse struct{}
 
-// Point is a single point located at the object's position
-type Point struct{}
+// Capsule is a rectangle with rounded ends, the size of the object
+type Capsule struct{}
+
+// PointShape is a single point located at the object's position
+type PointShape struct{}
+
+// ShapeKind is the kind of shape of an object
+type ShapeKind int
+
+const (
+	// ShapeRectangle is a rectangle the size of the object. Objects without
+	// any other shape are rectangles.
+	ShapeRectangle ShapeKind = iota
+	// ShapeEllipse is an ellipse the size of the object
+	ShapeEllipse
+	// ShapePoint is a single point at the object's position
+	ShapePoint
+	// ShapePolygon is a closed polygon
+	ShapePolygon
+	// ShapePolyline is a polygon that doesn't have to close
+	ShapePolyline
+	// ShapeText is text drawn in the object's bounds
+	ShapeText
+	// ShapeTile is a tile drawn at the object's position
+	ShapeTile
+	// ShapeCapsule is a rectangle with rounded ends the size of the object
+	ShapeCapsule
+)
+
+var shapeKindNames = []string{
+	"rectangle",
+	"ellipse",
+	"point",
+	"polygon",
+	"polyline",
+	"text",
+	"tile",
+	"capsule",
+}
+
+func (k ShapeKind) String() string {
+	if k < 0 || int(k) >= len(shapeKindNames) {
+		return "ShapeKind(" + strconv.Itoa(int(k)) + ")"
+	}
+	return shapeKindNames[k]
+}
+
+// Shape is the shape of an object along with its geometry
+type Shape struct {
+	// Kind is the kind of shape
+	Kind ShapeKind
+	// Width is the width in pixels of rectangles, ellipses, capsules, text and
+	// tiles
+	Width float64
+	// Height is the height in pixels of rectangles, ellipses, capsules, text
+	// and tiles
+	Height float64
+	// Points are the vertices of polygons and polylines in pixels, relative to
+	// the position of the object
+	Points Points
+	// Text is the text of text objects
+	Text *Text
+	// GID is the global tile ID of tile objects, including any flipping flags
+	GID uint32
+}
+
+// Shape returns the shape of the object, so it can be switched on by kind
+func (o Object) Shape() Shape {
+	s := Shape{
+		Width:  o.Width,
+		Height: o.Height,
+	}
+	switch {
+	case o.GID != 0:
+		s.Kind = ShapeTile
+		s.GID = o.GID
+	case len(o.Ellipses) > 0:
+		s.Kind = ShapeEllipse
+	case len(o.Points) > 0:
+		s.Kind = ShapePoint
+		s.Width, s.Height = 0, 0
+	case len(o.Polygons) > 0:
+		s.Kind = ShapePolygon
+		s.Points = o.Polygons[0].Points
+	case len(o.Polylines) > 0:
+		s.Kind = ShapePolyline
+		s.Points = o.Polylines[0].Points
+	case len(o.Text) > 0:
+		s.Kind = ShapeText
+		s.Text = &o.Text[0]
+	case len(o.Capsules) > 0:
+		s.Kind = ShapeCapsule
+	}
+	return s
+}
+
+// Point is a position in pixels
+type Point struct {
+	// X is the x coordinate in pixels
+	X float64
+	// Y is the y coordinate in pixels
+	Y float64
+}
+
+// Points are a list of points, stored in TMX files as space separated x,y
+// coordinate pairs such as "0,0 10,5 -3.5,8"
+type Points []Point
 
 // Polygon is a polygon shape
 type Polygon struct {
-	// Points are a list of x,y coordinates in pixels
-	Points string `xml:"points,attr"`
+	// Points are the vertices of the polygon in pixels, relative to the
+	// position of the object
+	Points Points `xml:"points,attr"`
+}
+
+// WorldPoints returns the vertices of the polygon moved to the position of the
+// object and rotated by its rotation
+func (p Polygon) WorldPoints(o Object) Points {
+	return p.Points.toWorld(o)
 }
 
 // Polyline is a polygon that doesn't have to close
 type Polyline struct {
-	// Points are a list of x,y coordinates in pixels
-	Points string `xml:"points,attr"`
+	// Points are the vertices of the polyline in pixels, relative to the
+	// position of the object
+	Points Points `xml:"points,attr"`
+}
+
+// WorldPoints returns the vertices of the polyline moved to the position of
+// the object and rotated by its rotation
+func (p Polyline) WorldPoints(o Object) Points {
+	return p.Points.toWorld(o)
+}
+
+// ToWorld returns the point, given relative to the object's position, moved
+// to the position of the object and rotated clockwise around it by the
+// object's rotation
+func (o Object) ToWorld(p Point) Point {
+	if o.Rotation != 0 {
+		sin, cos := math.Sincos(o.Rotation * math.Pi / 180)
+		p = Point{
+			X: p.X*cos - p.Y*sin,
+			Y: p.X*sin + p.Y*cos,
+		}
+	}
+	return Point{
+		X: o.X + p.X,
+		Y: o.Y + p.Y,
+	}
+}
+
+func (pts Points) toWorld(o Object) Points {
+	ret := make(Points, len(pts))
+	for i, p := range pts {
+		ret[i] = o.ToWorld(p)
+	}
+	return ret
+}
+
+// UnmarshalXMLAttr implements the encoding/xml UnmarshalerAttr interface
+func (pts *Points) UnmarshalXMLAttr(attr xml.Attr) error {
+	fields := strings.Fields(attr.Value)
+	*pts = make(Points, 0, len(fields))
+	for _, f := range fields {
+		c := strings.Split(f, ",")
+		if len(c) != 2 {
+			return errors.New("Invalid Point " + strconv.Quote(f))
+		}
+		x, err := strconv.ParseFloat(c[0], 64)
+		if err != nil {
+			return err
+		}
+		y, err := strconv.ParseFloat(c[1], 64)
+		if err != nil {
+			return err
+		}
+		*pts = append(*pts, Point{X: x, Y: y})
+	}
+	return nil
+}
+
+// MarshalXMLAttr implements the encoding/xml MarshalerAttr interface
+func (pts Points) MarshalXMLAttr(name xml.Name) (xml.Attr, error) {
+	s := make([]string, len(pts))
+	for i, p := range pts {
+		s[i] = formatFloat(p.X) + "," + formatFloat(p.Y)
+	}
+	return xml.Attr{Name: name, Value: strings.Join(s, " ")}, nil
 }
 
 // Text is a text object
@@ -127,6 +431,11 @@ type Text struct {
 type ImageLayer struct {
 	// Name is the name of the image layer
 	Name string `xml:"name,attr"`
+	// ID is the unique ID of the image layer, or 0 in maps saved before
+	// Tiled 1.2
+	ID int `xml:"id,attr"`
+	// Class is the class of the image layer
+	Class string `xml:"class,attr"`
 	// OffsetX is the rendering x offset of the image layer in pixels
 	OffsetX float64 `xml:"offsetx,attr"`
 	// OffsetY is the rendering y offset of the image layer in pixels
@@ -139,16 +448,41 @@ type ImageLayer struct {
 	Opacity float64 `xml:"opacity,attr"`
 	// Visibile indicates whether the layer is shown (1) or hidden (0)
 	Visible int `xml:"visible,attr"`
+	// ParallaxX is the horizontal parallax scrolling factor of the image.
+	// Defaults to 1.
+	ParallaxX float64 `xml:"parallaxx,attr"`
+	// ParallaxY is the vertical parallax scrolling factor of the image.
+	// Defaults to 1.
+	ParallaxY float64 `xml:"parallaxy,attr"`
+	// TintColor is multiplied with the image, in the form #AARRGGBB or #RRGGBB
+	TintColor string `xml:"tintcolor,attr"`
+	// Locked is whether the image layer is locked in the editor (1) or not (0)
+	Locked int `xml:"locked,attr"`
+	// RepeatX is whether the image is repeated horizontally (1) or not (0)
+	RepeatX int `xml:"repeatx,attr"`
+	// RepeatY is whether the image is repeated vertically (1) or not (0)
+	RepeatY int `xml:"repeaty,attr"`
 	// Properties are the properties of the layer
-	Properties []Property `xml:"properties>property"`
+	Properties Properties `xml:"properties>property"`
 	// Images are the images of the layer
 	Images []Image `xml:"image"`
+	// Index is the position of the layer among the layers of its map or group,
+	// in the order they appear in the file
+	Index int `xml:"-"`
+
+	// offset is where the layer was found in the file
+	offset int64
 }
 
 // Group is a root element to organize the layers
 type Group struct {
 	// Name is the name of the group layer
 	Name string `xml:"name,attr"`
+	// ID is the unique ID of the group layer, or 0 in maps saved before
+	// Tiled 1.2
+	ID int `xml:"id,attr"`
+	// Class is the class of the group layer
+	Class string `xml:"class,attr"`
 	// OffsetX is the x offset of the group layer in pixels
 	OffsetX float64 `xml:"offsetx,attr"`
 	// OffsetY is the y offset of the group layer in pixels
@@ -157,8 +491,19 @@ type Group struct {
 	Opacity float64 `xml:"opacity,attr"`
 	// Visible is whether the layer is shown (1) or hidden (0)
 	Visible int `xml:"visible,attr"`
+	// ParallaxX is multiplied with the horizontal parallax factors of the
+	// layers in the group. Defaults to 1.
+	ParallaxX float64 `xml:"parallaxx,attr"`
+	// ParallaxY is multiplied with the vertical parallax factors of the
+	// layers in the group. Defaults to 1.
+	ParallaxY float64 `xml:"parallaxy,attr"`
+	// TintColor is multiplied with the tint colors of the layers in the group
+	TintColor string `xml:"tintcolor,attr"`
+	// Locked is whether the layers in the group are all locked in the editor
+	// (1) or not (0)
+	Locked int `xml:"locked,attr"`
 	// Properties are the properties of the group
-	Properties []Property `xml:"properties>property"`
+	Properties Properties `xml:"properties>property"`
 	// Layers are the layers of the group
 	Layers []Layer `xml:"layer"`
 	// ObjectGroups are the object groups of the group
@@ -167,6 +512,17 @@ type Group struct {
 	ImageLayers []ImageLayer `xml:"imagelayer"`
 	// Groups are the child groups in the group
 	Group []Group `xml:"group"`
+	// Index is the position of the layer among the layers of its map or group,
+	// in the order they appear in the file
+	Index int `xml:"-"`
+
+	// offset is where the layer was found in the file
+	offset int64
+}
+
+// LayerNodes returns the layers of the group in the order they are drawn
+func (g *Group) LayerNodes() []LayerNode {
+	return layerNodes(g.Layers, g.ObjectGroups, g.ImageLayers, g.Group)
 }
 
 // UnmarshalXML implements the encoding/xml Unmarshaler interface
@@ -175,10 +531,14 @@ func (o *ObjectGroup) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error
 	og := objectGroup{
 		Opacity:   1,
 		Visible:   1,
+		ParallaxX: 1,
+		ParallaxY: 1,
 		DrawOrder: "topdown",
+		offset:    d.InputOffset(),
 	}
+	line := currentLine(d)
 	if err := d.DecodeElement(&og, &start); err != nil {
-		return err
+		return errorAt(err, elementPath("objectgroup", og.Name), line)
 	}
 	*o = (ObjectGroup)(og)
 	return nil
@@ -189,70 +549,84 @@ func (o *Object) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 	type object Object
 	obj := object{
 		Visible: 1,
+		line:    currentLine(d),
 	}
 	if err := d.DecodeElement(&obj, &start); err != nil {
-		return err
+		return errorAt(err, (*Object)(&obj).path(), obj.line)
 	}
 	*o = (Object)(obj)
-	if o.Template != "" {
-		tmpl := Template{}
-		f, err := os.Open(path.Join(path.Dir(TMXURL), o.Template))
-		defer f.Close()
-		if err != nil {
-			return err
-		}
-		b, _ := ioutil.ReadAll(f)
-		err = xml.Unmarshal(b, &tmpl)
-		if err != nil {
-			return err
-		}
-		if len(o.Ellipses) == 0 {
-			o.Ellipses = tmpl.Objects[0].Ellipses
-		}
-		if o.GID == 0 {
-			o.GID = tmpl.Objects[0].GID
-		}
-		if o.Height == 0 {
-			o.Height = tmpl.Objects[0].Height
-		}
-		if len(o.Images) == 0 {
-			o.Images = tmpl.Objects[0].Images
-		}
-		if o.Name == "" {
-			o.Name = tmpl.Objects[0].Name
-		}
-		if len(o.Polygons) == 0 {
-			o.Polygons = tmpl.Objects[0].Polygons
-		}
-		if len(o.Polylines) == 0 {
-			o.Polylines = tmpl.Objects[0].Polylines
-		}
-		if len(o.Properties) == 0 {
-			o.Properties = tmpl.Objects[0].Properties
-		}
-		if o.Rotation == 0 {
-			o.Rotation = tmpl.Objects[0].Rotation
-		}
-		if len(o.Text) == 0 {
-			o.Text = tmpl.Objects[0].Text
-		}
-		if o.Type == "" {
-			o.Type = tmpl.Objects[0].Type
-		}
-		if o.Visible == 1 {
-			o.Visible = tmpl.Objects[0].Visible
-		}
-		if o.Width == 0 {
-			o.Width = tmpl.Objects[0].Width
-		}
-		if o.X == 0 {
-			o.X = tmpl.Objects[0].X
+	names := make([]string, len(start.Attr))
+	for i, a := range start.Attr {
+		names[i] = a.Name.Local
+	}
+	o.setOverrides(names)
+	return nil
+}
+
+// applyTemplate fills in the fields the object doesn't override from the
+// first object of the template. gid is the template's gid as numbered in the
+// map. Properties are merged by name, with the object's own properties
+// replacing the template's.
+func (o *Object) applyTemplate(tmpl Template, gid uint32) {
+	t := &tmpl.Objects[0]
+	if !o.IsOverridden("name") {
+		o.Name = t.Name
+	}
+	if !o.IsOverridden("type") {
+		o.Type = t.Type
+	}
+	if !o.IsOverridden("gid") {
+		o.GID = gid
+	}
+	if !o.IsOverridden("x") {
+		o.X = t.X
+	}
+	if !o.IsOverridden("y") {
+		o.Y = t.Y
+	}
+	if !o.IsOverridden("width") {
+		o.Width = t.Width
+	}
+	if !o.IsOverridden("height") {
+		o.Height = t.Height
+	}
+	if !o.IsOverridden("rotation") {
+		o.Rotation = t.Rotation
+	}
+	if !o.IsOverridden("visible") {
+		o.Visible = t.Visible
+	}
+	if !o.IsOverridden("shape") {
+		o.Ellipses = t.Ellipses
+		o.Points = t.Points
+		o.Capsules = t.Capsules
+		o.Polygons = t.Polygons
+		o.Polylines = t.Polylines
+	}
+	if !o.IsOverridden("text") {
+		o.Text = t.Text
+	}
+	if !o.IsOverridden("image") {
+		o.Images = t.Images
+	}
+	props := make(Properties, 0, len(t.Properties)+len(o.Properties))
+	for _, p := range t.Properties {
+		if own, ok := o.Properties.Get(p.Name); ok {
+			p = own
 		}
-		if o.Y == 0 {
-			o.Y = tmpl.Objects[0].Y
+		props = append(props, p)
+	}
+	for _, p := range o.Properties {
+		if _, ok := t.Properties.Get(p.Name); !ok {
+			props = append(props, p)
 		}
 	}
-	return nil
+	if len(props) > 0 {
+		o.Properties = props
+	}
+	applied := *t
+	applied.GID = gid
+	o.template = &applied
 }
 
 // UnmarshalXML implements the encoding/xml Unmarshaler interface
@@ -271,8 +645,9 @@ func (t *Text) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 		Halign:     "left",
 		Valign:     "top",
 	}
+	line := currentLine(d)
 	if err := d.DecodeElement(&txt, &start); err != nil {
-		return err
+		return errorAt(err, "text", line)
 	}
 	*t = (Text)(txt)
 	return nil
@@ -282,11 +657,15 @@ func (t *Text) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 func (i *ImageLayer) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 	type imageLayer ImageLayer
 	il := imageLayer{
-		Opacity: 1,
-		Visible: 1,
+		Opacity:   1,
+		Visible:   1,
+		ParallaxX: 1,
+		ParallaxY: 1,
+		offset:    d.InputOffset(),
 	}
+	line := currentLine(d)
 	if err := d.DecodeElement(&il, &start); err != nil {
-		return err
+		return errorAt(err, elementPath("imagelayer", il.Name), line)
 	}
 	*i = (ImageLayer)(il)
 	return nil
@@ -296,12 +675,252 @@ func (i *ImageLayer) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error
 func (g *Group) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 	type group Group
 	gr := group{
-		Opacity: 1,
-		Visible: 1,
+		Opacity:   1,
+		Visible:   1,
+		ParallaxX: 1,
+		ParallaxY: 1,
+		offset:    d.InputOffset(),
 	}
+	line := currentLine(d)
 	if err := d.DecodeElement(&gr, &start); err != nil {
-		return err
+		return errorAt(err, elementPath("group", gr.Name), line)
 	}
 	*g = (Group)(gr)
+	indexLayers(g.Layers, g.ObjectGroups, g.ImageLayers, g.Group)
 	return nil
 }
+
+// MarshalXML implements the encoding/xml Marshaler interface
+func (o ObjectGroup) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	a := attrs{}
+	a.int("id", o.ID, 0)
+	a.add("name", o.Name)
+	a.str("class", o.Class, "")
+	a.str("color", o.Color, "")
+	a.int("x", o.X, 0)
+	a.int("y", o.Y, 0)
+	a.int("width", o.Width, 0)
+	a.float("opacity", o.Opacity, 1)
+	a.int("visible", o.Visible, 1)
+	a.float("offsetx", o.OffsetX, 0)
+	a.float("offsety", o.OffsetY, 0)
+	a.str("draworder", o.DrawOrder, "topdown")
+	a.float("parallaxx", o.ParallaxX, 1)
+	a.float("parallaxy", o.ParallaxY, 1)
+	a.str("tintcolor", o.TintColor, "")
+	a.int("locked", o.Locked, 0)
+	start, err := encodeStart(e, start, a)
+	if err != nil {
+		return err
+	}
+	if err = encodeProperties(e, o.Properties); err != nil {
+		return err
+	}
+	for _, obj := range o.Objects {
+		if err = e.EncodeElement(obj, startElement("object")); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// MarshalXML implements the encoding/xml Marshaler interface
+func (o Object) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	a := attrs{}
+	a.uint("id", o.ID, 0)
+	a.str("template", o.Template, "")
+	props := o.Properties
+	if o.Template != "" && o.overrides != nil {
+		// Only what the object overrides is written, so the rest still comes
+		// from the template when it's read again
+		for _, f := range objectFields {
+			if o.IsOverridden(f) {
+				a.add(f, o.field(f))
+			}
+		}
+		props = nil
+		for _, p := range o.Properties {
+			if o.ownProperty(p) {
+				props = append(props, p)
+			}
+		}
+	} else {
+		a.str("name", o.Name, "")
+		a.str("type", o.Type, "")
+		a.uint("gid", o.GID, 0)
+		a.add("x", formatFloat(o.X))
+		a.add("y", formatFloat(o.Y))
+		a.float("width", o.Width, 0)
+		a.float("height", o.Height, 0)
+		a.float("rotation", o.Rotation, 0)
+		a.int("visible", o.Visible, 1)
+	}
+	start, err := encodeStart(e, start, a)
+	if err != nil {
+		return err
+	}
+	if err = encodeProperties(e, props); err != nil {
+		return err
+	}
+	if o.IsOverridden("shape") {
+		if err = o.encodeShapes(e); err != nil {
+			return err
+		}
+	}
+	if o.IsOverridden("text") {
+		for _, t := range o.Text {
+			if err = e.EncodeElement(t, startElement("text")); err != nil {
+				return err
+			}
+		}
+	}
+	if o.IsOverridden("image") {
+		for _, i := range o.Images {
+			if err = e.EncodeElement(i, startElement("image")); err != nil {
+				return err
+			}
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// field returns the value of the attribute of the object named f, as it's
+// written in the TMX format
+func (o *Object) field(f string) string {
+	switch f {
+	case "name":
+		return o.Name
+	case "type":
+		return o.Type
+	case "gid":
+		return strconv.FormatUint(uint64(o.GID), 10)
+	case "x":
+		return formatFloat(o.X)
+	case "y":
+		return formatFloat(o.Y)
+	case "width":
+		return formatFloat(o.Width)
+	case "height":
+		return formatFloat(o.Height)
+	case "rotation":
+		return formatFloat(o.Rotation)
+	case "visible":
+		return strconv.Itoa(o.Visible)
+	}
+	return ""
+}
+
+// encodeShapes writes the shape elements of the object
+func (o *Object) encodeShapes(e *xml.Encoder) error {
+	var err error
+	for _, el := range o.Ellipses {
+		if err = e.EncodeElement(el, startElement("ellipse")); err != nil {
+			return err
+		}
+	}
+	for range o.Points {
+		if err = encodeEmpty(e, startElement("point"), nil); err != nil {
+			return err
+		}
+	}
+	for range o.Capsules {
+		if err = encodeEmpty(e, startElement("capsule"), nil); err != nil {
+			return err
+		}
+	}
+	for _, p := range o.Polygons {
+		if err = e.EncodeElement(p, startElement("polygon")); err != nil {
+			return err
+		}
+	}
+	for _, p := range o.Polylines {
+		if err = e.EncodeElement(p, startElement("polyline")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarshalXML implements the encoding/xml Marshaler interface
+func (t Text) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	a := attrs{}
+	a.str("fontfamily", t.FontFamily, "sans-serif")
+	a.float("pixelsize", t.PixelSize, 16)
+	a.int("wrap", t.Wrap, 0)
+	a.str("color", t.Color, "#000000")
+	a.int("bold", t.Bold, 0)
+	a.int("italic", t.Italic, 0)
+	a.int("underline", t.Underline, 0)
+	a.int("strikeout", t.Strikeout, 0)
+	a.int("kerning", t.Kerning, 1)
+	a.str("halign", t.Halign, "left")
+	a.str("valign", t.Valign, "top")
+	start, err := encodeStart(e, start, a)
+	if err != nil {
+		return err
+	}
+	if err = e.EncodeToken(xml.CharData(t.CharData)); err != nil {
+		return err
+	}
+	return e.EncodeToken(start.End())
+}
+
+// MarshalXML implements the encoding/xml Marshaler interface
+func (i ImageLayer) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	a := attrs{}
+	a.int("id", i.ID, 0)
+	a.add("name", i.Name)
+	a.str("class", i.Class, "")
+	a.float("offsetx", i.OffsetX, 0)
+	a.float("offsety", i.OffsetY, 0)
+	a.float("x", i.X, 0)
+	a.float("y", i.Y, 0)
+	a.float("opacity", i.Opacity, 1)
+	a.int("visible", i.Visible, 1)
+	a.float("parallaxx", i.ParallaxX, 1)
+	a.float("parallaxy", i.ParallaxY, 1)
+	a.str("tintcolor", i.TintColor, "")
+	a.int("locked", i.Locked, 0)
+	a.int("repeatx", i.RepeatX, 0)
+	a.int("repeaty", i.RepeatY, 0)
+	start, err := encodeStart(e, start, a)
+	if err != nil {
+		return err
+	}
+	if err = encodeProperties(e, i.Properties); err != nil {
+		return err
+	}
+	for _, img := range i.Images {
+		if err = e.EncodeElement(img, startElement("image")); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// MarshalXML implements the encoding/xml Marshaler interface
+func (g Group) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	a := attrs{}
+	a.int("id", g.ID, 0)
+	a.add("name", g.Name)
+	a.str("class", g.Class, "")
+	a.float("offsetx", g.OffsetX, 0)
+	a.float("offsety", g.OffsetY, 0)
+	a.float("opacity", g.Opacity, 1)
+	a.int("visible", g.Visible, 1)
+	a.float("parallaxx", g.ParallaxX, 1)
+	a.float("parallaxy", g.ParallaxY, 1)
+	a.str("tintcolor", g.TintColor, "")
+	a.int("locked", g.Locked, 0)
+	start, err := encodeStart(e, start, a)
+	if err != nil {
+		return err
+	}
+	if err = encodeProperties(e, g.Properties); err != nil {
+		return err
+	}
+	if err = encodeLayers(e, g.LayerNodes()); err != nil {
+		return err
+	}
+	return e.EncodeToken(start.End())
+}
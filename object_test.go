@@ -1,8 +1,14 @@
 package tmx
 
 import (
+	"bytes"
+	"errors"
+	"math"
 	"os"
+	"strconv"
+	"strings"
 	"testing"
+	"testing/fstest"
 )
 
 func TestObjectExternal(t *testing.T) {
@@ -238,3 +244,424 @@ a multiline value` {
 	}
 
 }
+
+func TestObjectPolygons(t *testing.T) {
+	TMXURL = "testData/polygons.tmx"
+	f, err := os.Open(TMXURL)
+	if err != nil {
+		t.Errorf("Unable to open %v. Error was: %v", TMXURL, err)
+		return
+	}
+	defer f.Close()
+	m, err := Parse(f)
+	if err != nil {
+		t.Errorf("Unable to parse polygons. Error was: %v", err)
+		return
+	}
+	polygon := m.ObjectGroups[0].Objects[0]
+	exp := Points{Point{0, 0}, Point{10, 5}, Point{-3.5, 8}}
+	if !pointsEqual(polygon.Polygons[0].Points, exp) {
+		t.Errorf("Polygon points not properly parsed\nWanted: %v\nGot: %v", exp, polygon.Polygons[0].Points)
+	}
+	exp = Points{Point{10, 20}, Point{20, 25}, Point{6.5, 28}}
+	if got := polygon.Polygons[0].WorldPoints(polygon); !pointsEqual(got, exp) {
+		t.Errorf("Polygon world points not properly calculated\nWanted: %v\nGot: %v", exp, got)
+	}
+	polyline := m.ObjectGroups[0].Objects[1]
+	exp = Points{Point{100, 50}, Point{100, 60}, Point{80, 60}}
+	if got := polyline.Polylines[0].WorldPoints(polyline); !pointsEqual(got, exp) {
+		t.Errorf("Rotated polyline world points not properly calculated\nWanted: %v\nGot: %v", exp, got)
+	}
+}
+
+func pointsEqual(a, b Points) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if math.Abs(a[i].X-b[i].X) > 1e-9 || math.Abs(a[i].Y-b[i].Y) > 1e-9 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestObjectPolygonsMalformed(t *testing.T) {
+	for _, name := range []string{"testData/malformedPolygon.tmx", "testData/malformedPolyline.tmx"} {
+		TMXURL = name
+		f, err := os.Open(TMXURL)
+		if err != nil {
+			t.Errorf("Unable to open %v. Error was: %v", TMXURL, err)
+			return
+		}
+		_, err = Parse(f)
+		f.Close()
+		if err == nil {
+			t.Errorf("Able to parse %v with malformed points", TMXURL)
+		}
+	}
+}
+
+func TestObjectPolygonsEncode(t *testing.T) {
+	m, m2, _ := roundTrip(t, "polygons.tmx")
+	exp := m.ObjectGroups[0].Objects[0].Polygons[0].Points
+	if got := m2.ObjectGroups[0].Objects[0].Polygons[0].Points; !pointsEqual(got, exp) {
+		t.Errorf("Encoded polygon points do not match\nWanted: %v\nGot: %v", exp, got)
+	}
+}
+
+func checkShapes(t *testing.T, m Map) {
+	exp := []ShapeKind{ShapeRectangle, ShapeEllipse, ShapePoint, ShapePolygon, ShapePolyline, ShapeText, ShapeTile, ShapeCapsule}
+	objs := m.ObjectGroups[0].Objects
+	if len(objs) != len(exp) {
+		t.Errorf("Wrong number of objects\nWanted: %v\nGot: %v", len(exp), len(objs))
+		return
+	}
+	for i, o := range objs {
+		s := o.Shape()
+		if s.Kind != exp[i] {
+			t.Errorf("Wrong shape for object %v\nWanted: %v\nGot: %v", o.Name, exp[i], s.Kind)
+		}
+		if s.Kind.String() != o.Name {
+			t.Errorf("Wrong shape name\nWanted: %v\nGot: %v", o.Name, s.Kind.String())
+		}
+	}
+	if s := objs[0].Shape(); s.Width != 3 || s.Height != 4 {
+		t.Errorf("Rectangle size not set. Got: %vx%v", s.Width, s.Height)
+	}
+	if s := objs[3].Shape(); len(s.Points) != 3 {
+		t.Errorf("Polygon points not set. Got: %v", s.Points)
+	}
+	if s := objs[5].Shape(); s.Text == nil || s.Text.CharData != "Hi" {
+		t.Errorf("Text not set. Got: %v", s.Text)
+	}
+	if s := objs[6].Shape(); s.GID != 3|HorizontalFlipFlag {
+		t.Errorf("Tile GID not set\nWanted: %v\nGot: %v", 3|HorizontalFlipFlag, s.GID)
+	}
+	if s := objs[7].Shape(); s.Width != 3 || s.Height != 8 {
+		t.Errorf("Capsule size not set. Got: %vx%v", s.Width, s.Height)
+	}
+}
+
+func TestObjectShapes(t *testing.T) {
+	m, err := ParseFS(os.DirFS("testData"), "shapes.tmx")
+	if err != nil {
+		t.Errorf("Unable to parse shapes.tmx. Error was: %v", err)
+		return
+	}
+	checkShapes(t, m)
+	_, m, _ = roundTrip(t, "shapes.tmx")
+	checkShapes(t, m)
+	if k := ShapeKind(20).String(); k != "ShapeKind(20)" {
+		t.Errorf("Wrong name for unknown shape kind\nWanted: %v\nGot: %v", "ShapeKind(20)", k)
+	}
+}
+
+func TestObjectShapesJSON(t *testing.T) {
+	m, err := ParseJSON(strings.NewReader(`{"layers":[{"type":"objectgroup","objects":[
+ {"id":1, "point":true},
+ {"id":2, "capsule":true, "width":3, "height":8}
+]}]}`))
+	if err != nil {
+		t.Errorf("Unable to parse JSON shapes. Error was: %v", err)
+		return
+	}
+	objs := m.ObjectGroups[0].Objects
+	if k := objs[0].Shape().Kind; k != ShapePoint {
+		t.Errorf("Wrong shape for JSON point\nWanted: %v\nGot: %v", ShapePoint, k)
+	}
+	if k := objs[1].Shape().Kind; k != ShapeCapsule {
+		t.Errorf("Wrong shape for JSON capsule\nWanted: %v\nGot: %v", ShapeCapsule, k)
+	}
+}
+
+func templateFS() fstest.MapFS {
+	tileset := func(name string) *fstest.MapFile {
+		return &fstest.MapFile{Data: []byte(`<tileset name="` + name + `" tilewidth="16" tileheight="16" tilecount="4" columns="4">
+ <image source="tiles.png" width="64" height="16"/>
+</tileset>`)}
+	}
+	template := func(source string, gid uint32) *fstest.MapFile {
+		return &fstest.MapFile{Data: []byte(`<template>
+ <tileset firstgid="1" source="` + source + `"/>
+ <object name="Sprite" gid="` + strconv.FormatUint(uint64(gid), 10) + `" width="16" height="16"/>
+</template>`)}
+	}
+	return fstest.MapFS{
+		"maps/level.tmx": &fstest.MapFile{Data: []byte(`<map width="1" height="1" tilewidth="16" tileheight="16">
+ <tileset firstgid="5" source="../tilesets/a.tsx"/>
+ <objectgroup name="Objects">
+  <object id="1" template="../templates/a.tx"/>
+  <object id="2" template="../templates/b.tx"/>
+  <object id="3" template="../templates/b.tx" x="16"/>
+  <object id="4" template="../templates/b.tx" gid="5"/>
+ </objectgroup>
+</map>`)},
+		"tilesets/a.tsx": tileset("a"),
+		"tilesets/b.tsx": tileset("b"),
+		"templates/a.tx": template("../tilesets/a.tsx", 3|HorizontalFlipFlag),
+		"templates/b.tx": template("../tilesets/b.tsx", 2),
+		"templates/empty.tx": &fstest.MapFile{Data: []byte(`<template>
+ <tileset firstgid="1" source="../tilesets/a.tsx"/>
+</template>`)},
+		"templates/empty.tj": &fstest.MapFile{Data: []byte(`{"type":"template"}`)},
+		// A GID below the first GID of the template's only tileset
+		"templates/range.tx": &fstest.MapFile{Data: []byte(`<template>
+ <tileset firstgid="3" source="../tilesets/a.tsx"/>
+ <object name="Sprite" gid="2"/>
+</template>`)},
+	}
+}
+
+func TestObjectTemplateGID(t *testing.T) {
+	m, err := NewLoader(templateFS()).Parse("maps/level.tmx")
+	if err != nil {
+		t.Fatalf("Unable to parse maps/level.tmx. Error was: %v", err)
+	}
+	objs := m.ObjectGroups[0].Objects
+	exp := []uint32{7 | HorizontalFlipFlag, 10, 10, 5}
+	for i, gid := range exp {
+		if objs[i].GID != gid {
+			t.Errorf("Object %v GID was not remapped into the map\nWanted: %v\nGot: %v", objs[i].ID, gid, objs[i].GID)
+		}
+	}
+	if len(m.Tilesets) != 2 {
+		t.Fatalf("Template tileset was not merged into the map once\nWanted: %v\nGot: %v", 2, len(m.Tilesets))
+	}
+	if ts := m.Tilesets[1]; ts.FirstGID != 9 || ts.Source != "../tilesets/b.tsx" || ts.Name != "b" {
+		t.Errorf("Merged tileset was incorrect\nWanted: %v %v %v\nGot: %v %v %v", 9, "../tilesets/b.tsx", "b", ts.FirstGID, ts.Source, ts.Name)
+	}
+	if info, ok := m.TileForGID(objs[1].GID); !ok || info.Tileset.Name != "b" || info.ID != 1 {
+		t.Errorf("Remapped GID did not find the template's tile")
+	}
+}
+
+func TestObjectTemplateGIDOverridden(t *testing.T) {
+	fsys := templateFS()
+	fsys["maps/level.tmx"] = &fstest.MapFile{Data: []byte(`<map width="1" height="1" tilewidth="16" tileheight="16">
+ <tileset firstgid="1" source="../tilesets/a.tsx"/>
+ <objectgroup name="Objects">
+  <object id="1" template="../templates/b.tx" gid="3"/>
+ </objectgroup>
+</map>`)}
+	m, err := NewLoader(fsys).Parse("maps/level.tmx")
+	if err != nil {
+		t.Fatalf("Unable to parse maps/level.tmx. Error was: %v", err)
+	}
+	if gid := m.ObjectGroups[0].Objects[0].GID; gid != 3 {
+		t.Errorf("Overridden GID was changed\nWanted: %v\nGot: %v", 3, gid)
+	}
+	if len(m.Tilesets) != 1 {
+		t.Errorf("Unused template tileset was merged into the map\nWanted: %v\nGot: %v", 1, len(m.Tilesets))
+	}
+}
+
+func TestObjectTemplateEmbeddedTileset(t *testing.T) {
+	embedded := func(size string) string {
+		return `<tileset firstgid="1" name="tiles" tilewidth="` + size + `" tileheight="` + size + `" tilecount="4" columns="4">
+  <image source="../tiles.png" width="64" height="16"/>
+ </tileset>`
+	}
+	template := func(size string) *fstest.MapFile {
+		return &fstest.MapFile{Data: []byte(`<template>
+ ` + embedded(size) + `
+ <object name="Sprite" gid="2"/>
+</template>`)}
+	}
+	fsys := fstest.MapFS{
+		"maps/level.tmx": &fstest.MapFile{Data: []byte(`<map width="1" height="1" tilewidth="16" tileheight="16">
+ ` + embedded("16") + `
+ <objectgroup name="Objects">
+  <object id="1" template="../templates/same.tx"/>
+  <object id="2" template="../templates/other.tx"/>
+ </objectgroup>
+</map>`)},
+		"templates/same.tx":  template("16"),
+		"templates/other.tx": template("32"),
+	}
+	m, err := NewLoader(fsys).Parse("maps/level.tmx")
+	if err != nil {
+		t.Fatalf("Unable to parse maps/level.tmx. Error was: %v", err)
+	}
+	// Only the tileset with the other tile size is a different tileset
+	if len(m.Tilesets) != 2 {
+		t.Fatalf("Embedded template tilesets were not matched by geometry\nWanted: %v\nGot: %v", 2, len(m.Tilesets))
+	}
+	objs := m.ObjectGroups[0].Objects
+	if objs[0].GID != 2 || objs[1].GID != 6 {
+		t.Errorf("Wrong GIDs for embedded template tilesets\nWanted: %v %v\nGot: %v %v", 2, 6, objs[0].GID, objs[1].GID)
+	}
+}
+
+func TestObjectTemplateInvalid(t *testing.T) {
+	tests := []struct {
+		template string
+		err      error
+	}{
+		{"empty.tx", ErrEmptyTemplate},
+		{"empty.tj", ErrEmptyTemplate},
+		{"range.tx", ErrGIDRange},
+	}
+	for _, test := range tests {
+		fsys := templateFS()
+		fsys["maps/level.tmx"] = &fstest.MapFile{Data: []byte(`<map width="1" height="1" tilewidth="16" tileheight="16">
+ <objectgroup name="Objects">
+  <object id="1" template="../templates/` + test.template + `"/>
+ </objectgroup>
+</map>`)}
+		_, err := NewLoader(fsys).Parse("maps/level.tmx")
+		var re *ExternalRefError
+		if !errors.As(err, &re) || !errors.Is(err, test.err) {
+			t.Errorf("Error parsing %v was incorrect\nWanted: %v\nGot: %v", test.template, test.err, err)
+		}
+	}
+}
+
+func overrideFS(object string) fstest.MapFS {
+	return fstest.MapFS{
+		"map.tmx": &fstest.MapFile{Data: []byte(`<map width="1" height="1" tilewidth="16" tileheight="16">
+ <objectgroup name="Objects">
+  ` + object + `
+ </objectgroup>
+</map>`)},
+		"map.tmj": &fstest.MapFile{Data: []byte(`{"orientation":"orthogonal","width":1,"height":1,"tilewidth":16,"tileheight":16,
+ "layers":[{"type":"objectgroup","name":"Objects","objects":[` + object + `]}]}`)},
+		"wheel.tx": &fstest.MapFile{Data: []byte(`<template>
+ <object name="Wheel" type="Part" x="5" y="6" width="15" height="40" rotation="45" visible="0">
+  <properties>
+   <property name="a" type="int" value="1"/>
+   <property name="b" type="int" value="2"/>
+  </properties>
+  <ellipse/>
+ </object>
+</template>`)},
+	}
+}
+
+func checkOverrides(t *testing.T, o Object) {
+	t.Helper()
+	if o.Name != "Wheel" || o.X != 0 || o.Y != 6 || o.Width != 15 || o.Rotation != 0 || o.Visible != 1 || len(o.Ellipses) != 1 {
+		t.Errorf("Template was not applied around the overrides\nGot: %+v", o)
+	}
+	props := []string{"a=1", "b=3", "c=4"}
+	if len(o.Properties) != len(props) {
+		t.Fatalf("Properties were not merged by name\nWanted: %v\nGot: %v", props, o.Properties)
+	}
+	for i, p := range o.Properties {
+		if got := p.Name + "=" + p.Value; got != props[i] {
+			t.Errorf("Properties were not merged by name\nWanted: %v\nGot: %v", props[i], got)
+		}
+	}
+	if o.Template != "wheel.tx" {
+		t.Errorf("Template reference was not kept\nWanted: %v\nGot: %v", "wheel.tx", o.Template)
+	}
+	for field, exp := range map[string]bool{"x": true, "rotation": true, "visible": true, "properties": true, "y": false, "name": false, "shape": false} {
+		if o.IsOverridden(field) != exp {
+			t.Errorf("IsOverridden(%v) was incorrect\nWanted: %v\nGot: %v", field, exp, !exp)
+		}
+	}
+}
+
+func TestObjectTemplateOverrides(t *testing.T) {
+	fsys := overrideFS(`<object id="1" template="wheel.tx" x="0" rotation="0" visible="1">
+   <properties>
+    <property name="b" type="int" value="3"/>
+    <property name="c" type="int" value="4"/>
+   </properties>
+  </object>`)
+	m, err := NewLoader(fsys).Parse("map.tmx")
+	if err != nil {
+		t.Fatalf("Unable to parse map.tmx. Error was: %v", err)
+	}
+	checkOverrides(t, m.ObjectGroups[0].Objects[0])
+}
+
+func TestObjectTemplateOverridesJSON(t *testing.T) {
+	fsys := overrideFS(`{"id":1,"template":"wheel.tx","x":0,"rotation":0,"visible":true,
+  "properties":[{"name":"b","type":"int","value":3},{"name":"c","type":"int","value":4}]}`)
+	m, err := NewLoader(fsys).Parse("map.tmj")
+	if err != nil {
+		t.Fatalf("Unable to parse map.tmj. Error was: %v", err)
+	}
+	checkOverrides(t, m.ObjectGroups[0].Objects[0])
+}
+
+func TestEncodeObjectTemplateOverrides(t *testing.T) {
+	fsys := overrideFS(`<object id="1" template="wheel.tx" x="0" rotation="0" visible="1">
+   <properties>
+    <property name="b" type="int" value="3"/>
+    <property name="c" type="int" value="4"/>
+   </properties>
+  </object>`)
+	m, err := NewLoader(fsys).Parse("map.tmx")
+	if err != nil {
+		t.Fatalf("Unable to parse map.tmx. Error was: %v", err)
+	}
+	var buf bytes.Buffer
+	if err = Encode(&buf, m); err != nil {
+		t.Fatalf("Unable to encode map. Error was: %v", err)
+	}
+	out := buf.String()
+	for _, s := range []string{`x="0"`, `rotation="0"`, `visible="1"`, `name="b"`, `name="c"`} {
+		if !strings.Contains(out, s) {
+			t.Errorf("Encoded object is missing override %v\nGot: %v", s, out)
+		}
+	}
+	for _, s := range []string{`name="Wheel"`, `y="6"`, `name="a"`, `<ellipse`} {
+		if strings.Contains(out, s) {
+			t.Errorf("Encoded object wrote %v from its template\nGot: %v", s, out)
+		}
+	}
+	m2, err := NewLoader(fsys).Decode(&buf, "map.tmx")
+	if err != nil {
+		t.Fatalf("Unable to parse encoded map. Error was: %v", err)
+	}
+	checkOverrides(t, m2.ObjectGroups[0].Objects[0])
+}
+
+func TestEncodeObjectTemplateEdited(t *testing.T) {
+	fsys := overrideFS(`<object id="1" template="wheel.tx"/>`)
+	m, err := NewLoader(fsys).Parse("map.tmx")
+	if err != nil {
+		t.Fatalf("Unable to parse map.tmx. Error was: %v", err)
+	}
+	o := &m.ObjectGroups[0].Objects[0]
+	if o.IsOverridden("x") {
+		t.Errorf("Field taken from the template was overridden")
+	}
+	// Fields changed in code are overridden, even though the file didn't set them
+	o.X = 50
+	o.Rotation = 90
+	o.Properties[0].Value = "7"
+	for _, f := range []string{"x", "rotation", "properties"} {
+		if !o.IsOverridden(f) {
+			t.Errorf("Edited field %v was not overridden", f)
+		}
+	}
+	var buf bytes.Buffer
+	if err = Encode(&buf, m); err != nil {
+		t.Fatalf("Unable to encode map. Error was: %v", err)
+	}
+	out := buf.String()
+	for _, s := range []string{`x="50"`, `rotation="90"`, `name="a"`} {
+		if !strings.Contains(out, s) {
+			t.Errorf("Encoded object is missing edited field %v\nGot: %v", s, out)
+		}
+	}
+	for _, s := range []string{`name="Wheel"`, `y="6"`, `name="b"`, `<ellipse`} {
+		if strings.Contains(out, s) {
+			t.Errorf("Encoded object wrote %v from its template\nGot: %v", s, out)
+		}
+	}
+	m2, err := NewLoader(fsys).Decode(&buf, "map.tmx")
+	if err != nil {
+		t.Fatalf("Unable to parse encoded map. Error was: %v", err)
+	}
+	o2 := m2.ObjectGroups[0].Objects[0]
+	if o2.X != 50 || o2.Rotation != 90 || o2.Y != 6 || o2.Name != "Wheel" {
+		t.Errorf("Edited object did not survive a round trip\nGot: %+v", o2)
+	}
+	if p, _ := o2.Properties.Get("a"); p.Value != "7" {
+		t.Errorf("Edited property did not survive a round trip\nWanted: %v\nGot: %v", "7", p.Value)
+	}
+}
@@ -1,23 +1,369 @@
 package tmx
 
 import (
+	"encoding/json"
 	"encoding/xml"
 	"io"
+	"io/fs"
 	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
 )
 
 // TMXURL is the URL to your TMX file. If it uses external files, the sources
 // given are relative to the location of the TMX file. This should be set if
 // you use external tilesets.
+//
+// Deprecated: TMXURL is only used by Parse. Use a Loader or ParseFS instead,
+// which resolve external files relative to the map they are given.
 var TMXURL string
 
-// Parse returns the Map encoded in the reader
+// Parse returns the Map encoded in the reader. External tilesets and templates
+// are opened from the operating system's file system relative to TMXURL.
 func Parse(r io.Reader) (Map, error) {
+	return NewLoader(osFS{}).Decode(r, TMXURL)
+}
+
+// ParseJSON returns the Map encoded in the reader in Tiled's JSON format
+// (.tmj). External tilesets and templates are opened from the operating
+// system's file system relative to TMXURL, and may be in either format.
+func ParseJSON(r io.Reader) (Map, error) {
+	return NewLoader(osFS{}).decode(r, TMXURL, json.Unmarshal)
+}
+
+// ParseFS returns the Map in the file name of fsys. External tilesets and
+// templates are resolved relative to name within fsys.
+func ParseFS(fsys fs.FS, name string) (Map, error) {
+	return NewLoader(fsys).Parse(name)
+}
+
+// Loader parses maps and the external tilesets and templates they reference.
+// Every source is resolved against FS relative to the file that references it,
+// so a Loader can read maps from disk, an embed.FS, a zip archive or an
+// in-memory file system.
+//
+// Each external tileset and template is read once and cached by its location
+// in FS, and shared by every map the Loader parses. Maps share the tiles,
+// properties and other slices of cached tilesets and templates, so they
+// shouldn't be modified in place. Use Invalidate when files change. A Loader
+// is safe for concurrent use.
+type Loader struct {
+	// FS is the file system maps and their external files are read from
+	FS fs.FS
+	// Strict makes Parse and Decode fail with the first problem found by
+	// Map.Validate. Otherwise the problems are kept in the map's Warnings.
+	Strict bool
+
+	cache loaderCache
+}
+
+// NewLoader returns a Loader that reads files from fsys
+func NewLoader(fsys fs.FS) *Loader {
+	return &Loader{FS: fsys}
+}
+
+// Parse returns the Map in the file name of the Loader's file system. The map
+// and the files it references can be in either the TMX or the JSON format.
+func (l *Loader) Parse(name string) (Map, error) {
+	f, err := l.FS.Open(name)
+	if err != nil {
+		return Map{}, err
+	}
+	defer f.Close()
+	return l.Decode(f, name)
+}
+
+// Decode returns the Map encoded in the reader in either the TMX or the JSON
+// format. name is the location of the map within the Loader's file system,
+// and is used to resolve any external tilesets and templates.
+func (l *Loader) Decode(r io.Reader, name string) (Map, error) {
+	return l.decode(r, name, unmarshal)
+}
+
+func (l *Loader) decode(r io.Reader, name string, unmarshal func([]byte, interface{}) error) (Map, error) {
 	var m Map
 	d, err := ioutil.ReadAll(r)
 	if err != nil {
 		return m, err
 	}
-	err = xml.Unmarshal(d, &m)
-	return m, err
+	if err = unmarshal(d, &m); err != nil {
+		return m, errorIn(err, name, d)
+	}
+	if err = l.resolveMap(&m, name); err != nil {
+		return m, err
+	}
+	m.Warnings = m.Validate()
+	for i := range m.Warnings {
+		m.Warnings[i] = errorIn(m.Warnings[i], name, d)
+	}
+	if l.Strict && len(m.Warnings) > 0 {
+		return m, m.Warnings[0]
+	}
+	return m, nil
+}
+
+// unmarshal decodes the data into v from Tiled's JSON format if it is a JSON
+// object, or from the XML format otherwise
+func unmarshal(data []byte, v interface{}) error {
+	if isJSON(data) {
+		return json.Unmarshal(data, v)
+	}
+	return xml.Unmarshal(data, v)
+}
+
+// decodeFile unmarshals the file name into v
+func (l *Loader) decodeFile(name string, v interface{}) error {
+	f, err := l.FS.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		return err
+	}
+	return errorIn(unmarshal(b, v), name, b)
+}
+
+// resolveMap loads the external tilesets and templates referenced by the map
+// stored in the file name, and records which file each element came from
+func (l *Loader) resolveMap(m *Map, name string) error {
+	m.Properties.setFile(name)
+	for i := range m.Tilesets {
+		if err := l.resolveTileset(&m.Tilesets[i], name, "map"); err != nil {
+			return err
+		}
+	}
+	if err := l.resolveLayers(m, m.Layers, m.ObjectGroups, m.ImageLayers, m.Groups, name, "map"); err != nil {
+		return err
+	}
+	m.IndexTilesets()
+	return nil
+}
+
+// resolveTileset loads the tileset's source, if it has one, relative to the
+// file name that references it. parent is the path of the element holding the
+// tileset, used in errors.
+func (l *Loader) resolveTileset(t *Tileset, name, parent string) error {
+	p := joinPath(parent, t.path())
+	if t.Source == "" {
+		t.Properties.setFile(name)
+		setImageFile(t.Image, name)
+		for i := range t.Tiles {
+			tile := &t.Tiles[i]
+			tile.Properties.setFile(name)
+			setImageFile(tile.Image, name)
+			tp := joinPath(p, elementPath("tile", strconv.FormatUint(uint64(tile.ID), 10)))
+			if err := l.resolveObjectGroups(nil, tile.ObjectGroup, name, tp); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	src := path.Join(path.Dir(name), t.Source)
+	t2, err := l.tileset(src)
+	if err != nil {
+		return &ExternalRefError{File: name, Path: p, Line: t.line, Source: src, Err: err}
+	}
+	t.Name = t2.Name
+	t.TileWidth = t2.TileWidth
+	t.TileHeight = t2.TileHeight
+	t.Spacing = t2.Spacing
+	t.Margin = t2.Margin
+	t.TileCount = t2.TileCount
+	t.Columns = t2.Columns
+	t.ObjectAlignment = t2.ObjectAlignment
+	t.TileRenderSize = t2.TileRenderSize
+	t.FillMode = t2.FillMode
+	t.TileOffset = t2.TileOffset
+	t.Grid = t2.Grid
+	t.Transformations = t2.Transformations
+	t.Image = t2.Image
+	t.Properties = t2.Properties
+	t.TerrainTypes = t2.TerrainTypes
+	t.Tiles = t2.Tiles
+	t.WangSets = t2.WangSets
+	return nil
+}
+
+func (l *Loader) resolveLayers(m *Map, layers []Layer, objectGroups []ObjectGroup, imageLayers []ImageLayer, groups []Group, name, parent string) error {
+	for i := range layers {
+		layers[i].Properties.setFile(name)
+		layers[i].IndexChunks()
+	}
+	for i := range imageLayers {
+		imageLayers[i].Properties.setFile(name)
+		setImageFile(imageLayers[i].Images, name)
+	}
+	if err := l.resolveObjectGroups(m, objectGroups, name, parent); err != nil {
+		return err
+	}
+	for i := range groups {
+		g := &groups[i]
+		g.Properties.setFile(name)
+		gp := joinPath(parent, elementPath("group", g.Name))
+		if err := l.resolveLayers(m, g.Layers, g.ObjectGroups, g.ImageLayers, g.Group, name, gp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *Loader) resolveObjectGroups(m *Map, groups []ObjectGroup, name, parent string) error {
+	for i := range groups {
+		groups[i].Properties.setFile(name)
+		gp := joinPath(parent, elementPath("objectgroup", groups[i].Name))
+		for j := range groups[i].Objects {
+			if err := l.resolveObject(m, &groups[i].Objects[j], name, gp); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// resolveObject applies the object's template, if it has one, relative to the
+// file name that references it. The template's tile, if it has one, is moved
+// into the GID space of the map m. m is nil for objects in tilesets, whose
+// templates keep their GIDs.
+func (l *Loader) resolveObject(m *Map, o *Object, name, parent string) error {
+	o.Properties.setFile(name)
+	setImageFile(o.Images, name)
+	if o.Template == "" {
+		return nil
+	}
+	src := path.Join(path.Dir(name), o.Template)
+	tmpl, err := l.template(src)
+	var gid uint32
+	// The template's tileset is only needed by the map when the object takes
+	// the template's gid
+	if err == nil && !o.IsOverridden("gid") {
+		gid = tmpl.Objects[0].GID
+		if m != nil {
+			gid, err = m.templateGID(tmpl, src, name)
+		}
+	}
+	if err != nil {
+		return &ExternalRefError{File: name, Path: joinPath(parent, o.path()), Line: o.line, Source: src, Err: err}
+	}
+	o.applyTemplate(*tmpl, gid)
+	return nil
+}
+
+// resolveTemplate loads the tilesets of the template stored in the file name
+func (l *Loader) resolveTemplate(tmpl *Template, name string) error {
+	if len(tmpl.Objects) == 0 {
+		return &ValidationError{File: name, Path: "template", Line: tmpl.line, Err: ErrEmptyTemplate}
+	}
+	for i := range tmpl.Objects {
+		tmpl.Objects[i].Properties.setFile(name)
+		setImageFile(tmpl.Objects[i].Images, name)
+	}
+	for i := range tmpl.Tilesets {
+		if err := l.resolveTileset(&tmpl.Tilesets[i], name, "template"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// templateGID returns the GID of the object of the template stored in the
+// file src, moved from the template's tilesets into the map's. If the map,
+// stored in the file name, doesn't have the template's tileset yet, it is
+// added after the map's last tileset.
+func (m *Map) templateGID(tmpl *Template, src, name string) (uint32, error) {
+	gid, flags := decodeGID(tmpl.Objects[0].GID)
+	if gid == 0 {
+		return 0, nil
+	}
+	var ts *Tileset
+	for i := range tmpl.Tilesets {
+		t := &tmpl.Tilesets[i]
+		if t.FirstGID <= gid && (ts == nil || t.FirstGID > ts.FirstGID) {
+			ts = t
+		}
+	}
+	if ts == nil {
+		o := &tmpl.Objects[0]
+		return 0, &ValidationError{File: src, Path: joinPath("template", o.path()), Line: o.line, Err: ErrGIDRange}
+	}
+	source := ""
+	if ts.Source != "" {
+		source = path.Join(path.Dir(src), ts.Source)
+	}
+	next := uint32(1)
+	for i := range m.Tilesets {
+		t := &m.Tilesets[i]
+		same := sameTileset(t, ts)
+		if t.Source != "" || source != "" {
+			same = t.Source != "" && path.Join(path.Dir(name), t.Source) == source
+		}
+		if same {
+			return (t.FirstGID + gid - ts.FirstGID) | flags, nil
+		}
+		if end := t.FirstGID + t.tileRange(); end > next {
+			next = end
+		}
+	}
+	merged := *ts
+	merged.FirstGID = next
+	if source != "" {
+		rel, err := relPath(path.Dir(name), source)
+		if err != nil {
+			return 0, err
+		}
+		merged.Source = rel
+	}
+	m.Tilesets = append(m.Tilesets, merged)
+	return (next + gid - ts.FirstGID) | flags, nil
+}
+
+// sameTileset returns whether the embedded tilesets a and b are the same
+// tileset, going by their name, tile geometry and images
+func sameTileset(a, b *Tileset) bool {
+	if a.Name != b.Name || a.TileWidth != b.TileWidth || a.TileHeight != b.TileHeight ||
+		a.TileCount != b.TileCount || a.Columns != b.Columns || len(a.Image) != len(b.Image) {
+		return false
+	}
+	for i := range a.Image {
+		if a.Image[i].Path() != b.Image[i].Path() {
+			return false
+		}
+	}
+	return true
+}
+
+// relPath returns the slash separated path target relative to the directory
+// dir. It fails with ErrUnreachableSource if dir climbs out of the file
+// system further than target does, since the way back down isn't known.
+func relPath(dir, target string) (string, error) {
+	split := func(p string) []string {
+		if p == "." || p == "" {
+			return nil
+		}
+		return strings.Split(p, "/")
+	}
+	d, t := split(dir), split(target)
+	for len(d) > 0 && len(t) > 0 && d[0] == t[0] {
+		d, t = d[1:], t[1:]
+	}
+	parts := make([]string, 0, len(d)+len(t))
+	for _, seg := range d {
+		if seg == ".." {
+			return "", ErrUnreachableSource
+		}
+		parts = append(parts, "..")
+	}
+	return path.Join(append(parts, t...)...), nil
+}
+
+// osFS opens files from the operating system without the path restrictions
+// of os.DirFS, so sources that climb out of the map's folder keep working
+// with Parse.
+type osFS struct{}
+
+func (osFS) Open(name string) (fs.File, error) {
+	return os.Open(name)
 }
@@ -2,7 +2,10 @@ package tmx
 
 import (
 	"errors"
+	"os"
+	"sync"
 	"testing"
+	"testing/fstest"
 )
 
 type failReader int
@@ -17,3 +20,97 @@ func TestParseFail(t *testing.T) {
 		t.Errorf("Parsed a reader when it threw an error")
 	}
 }
+
+func TestParseFS(t *testing.T) {
+	m, err := ParseFS(os.DirFS("testData"), "tilesheetTest.tmx")
+	if err != nil {
+		t.Errorf("Unable to parse tilesheetTest.tmx. Error was: %v", err)
+		return
+	}
+	if m.Tilesets[1].Image[0].Source != "roguelikeHoliday_transparent.png" {
+		t.Errorf("Image not properly parsed from external tileset")
+	}
+}
+
+func TestParseFSNotExist(t *testing.T) {
+	_, err := ParseFS(os.DirFS("testData"), "notExist.tmx")
+	if err == nil {
+		t.Errorf("Able to parse a map that does not exist")
+	}
+}
+
+func TestParseFSRelativeSources(t *testing.T) {
+	tsx, err := os.ReadFile("testData/external.tsx")
+	if err != nil {
+		t.Errorf("Unable to read external.tsx. Error was: %v", err)
+		return
+	}
+	tx, err := os.ReadFile("testData/Wheel.tx")
+	if err != nil {
+		t.Errorf("Unable to read Wheel.tx. Error was: %v", err)
+		return
+	}
+	fsys := fstest.MapFS{
+		"maps/level.tmx": &fstest.MapFile{Data: []byte(`<map width="1" height="1" tilewidth="16" tileheight="16">
+ <tileset firstgid="1" source="../tilesets/external.tsx"/>
+ <objectgroup name="Objects">
+  <object id="1" template="../templates/Wheel.tx" x="4" y="8"/>
+ </objectgroup>
+</map>`)},
+		"tilesets/external.tsx": &fstest.MapFile{Data: tsx},
+		"templates/Wheel.tx":    &fstest.MapFile{Data: tx},
+	}
+	m, err := NewLoader(fsys).Parse("maps/level.tmx")
+	if err != nil {
+		t.Errorf("Unable to parse maps/level.tmx. Error was: %v", err)
+		return
+	}
+	if m.Tilesets[0].Name != "external" {
+		t.Errorf("External tileset not resolved relative to the map\nWanted: %v\nGot: %v", "external", m.Tilesets[0].Name)
+	}
+	if p := m.Tilesets[0].Image[0].Path(); p != "tilesets/roguelikeHoliday_transparent.png" {
+		t.Errorf("Tileset image not resolved relative to the tileset\nWanted: %v\nGot: %v", "tilesets/roguelikeHoliday_transparent.png", p)
+	}
+	if m.ObjectGroups[0].Objects[0].Name != "Wheel" {
+		t.Errorf("Template not resolved relative to the map\nWanted: %v\nGot: %v", "Wheel", m.ObjectGroups[0].Objects[0].Name)
+	}
+}
+
+func TestParseFSConcurrent(t *testing.T) {
+	names := []string{"tilesheetTest.tmx", "objects.tmx", "tsxNotExist.tmx"}
+	errs := make([]error, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			_, errs[i] = ParseFS(os.DirFS("testData"), name)
+		}(i, name)
+	}
+	wg.Wait()
+	if errs[0] != nil || errs[1] != nil {
+		t.Errorf("Unable to parse maps concurrently. Errors were: %v, %v", errs[0], errs[1])
+	}
+	if errs[2] == nil {
+		t.Errorf("Able to parse %v when the tsx does not exist", names[2])
+	}
+}
+
+func TestRelPath(t *testing.T) {
+	tests := []struct {
+		dir, target, exp string
+		err              error
+	}{
+		{"maps", "tilesets/a.tsx", "../tilesets/a.tsx", nil},
+		{"maps/town", "maps/a.tsx", "../a.tsx", nil},
+		{".", "a.tsx", "a.tsx", nil},
+		{"../maps", "../tilesets/a.tsx", "../tilesets/a.tsx", nil},
+		{"../maps", "tilesets/a.tsx", "", ErrUnreachableSource},
+	}
+	for _, test := range tests {
+		rel, err := relPath(test.dir, test.target)
+		if rel != test.exp || err != test.err {
+			t.Errorf("Wrong path to %v from %v\nWanted: %q %v\nGot: %q %v", test.target, test.dir, test.exp, test.err, rel, err)
+		}
+	}
+}
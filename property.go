@@ -1,41 +1,176 @@
 package tmx
 
-import "encoding/xml"
+import (
+	"encoding/xml"
+	"errors"
+	"image/color"
+	"path"
+	"strconv"
+	"strings"
+)
 
 // Property is any custom data added to elements of the map
 type Property struct {
 	// Name is the name of the property
 	Name string `xml:"name,attr"`
 	// Type is the type of the property. It can be string, int, float, bool,
-	// color or file
+	// color, file, object or class
 	Type string `xml:"type,attr"`
+	// PropertyType is the name of the custom type of the property, for class
+	// and enum properties
+	PropertyType string `xml:"propertytype,attr,omitempty"`
 	// Value is the value of the property
 	Value string `xml:"value,attr"`
+	// Properties are the members of a class property
+	Properties Properties `xml:"properties>property"`
+
+	// file is the file the property was read from
+	file string
+}
+
+// Properties are the custom properties of an element
+type Properties []Property
+
+// Get returns the property with the name. It returns false if there is no
+// property with that name.
+func (ps Properties) Get(name string) (Property, bool) {
+	for _, p := range ps {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Property{}, false
+}
+
+// Int returns the value of an int property, or the ID of an object property
+func (p Property) Int() (int, error) {
+	return strconv.Atoi(p.Value)
+}
+
+// Float returns the value of a float or int property
+func (p Property) Float() (float64, error) {
+	return strconv.ParseFloat(p.Value, 64)
+}
+
+// Bool returns the value of a bool property
+func (p Property) Bool() (bool, error) {
+	return strconv.ParseBool(p.Value)
+}
+
+// Color returns the value of a color property. An unset color is transparent.
+func (p Property) Color() (color.NRGBA, error) {
+	return ParseColor(p.Value)
+}
+
+// File returns the path of a file property, relative to the file system the
+// map was loaded from. Paths are resolved relative to the file the property
+// was read from, which is not always the map, for example for properties of
+// external tilesets and templates.
+func (p Property) File() string {
+	if p.Value == "" || p.file == "" || path.IsAbs(p.Value) {
+		return p.Value
+	}
+	return path.Join(path.Dir(p.file), p.Value)
+}
+
+// Object returns the ID of the object referenced by an object property. An
+// ID of 0 means no object is referenced.
+func (p Property) Object() (uint32, error) {
+	id, err := strconv.ParseUint(p.Value, 10, 32)
+	return uint32(id), err
 }
 
+// setFile sets the file the properties were read from
+func (ps Properties) setFile(name string) {
+	for i := range ps {
+		ps[i].file = name
+		ps[i].Properties.setFile(name)
+	}
+}
+
+// ParseColor parses a color in Tiled's #AARRGGBB or #RRGGBB format, as used by
+// color properties and Map.BackgroundColor. The # is optional, and an empty
+// string is the zero color.
+func ParseColor(s string) (color.NRGBA, error) {
+	if s == "" {
+		return color.NRGBA{}, nil
+	}
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) != 6 && len(hex) != 8 {
+		return color.NRGBA{}, errors.New("Invalid Color")
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, err
+	}
+	if len(hex) == 6 {
+		v |= 0xff000000
+	}
+	return color.NRGBA{
+		A: uint8(v >> 24),
+		R: uint8(v >> 16),
+		G: uint8(v >> 8),
+		B: uint8(v),
+	}, nil
+}
+
+// UnmarshalXML implements the encoding/xml Unmarshaler interface
 func (p *Property) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 	prop := struct {
 		// Name is the name of the property
 		Name string `xml:"name,attr"`
 		// Type is the type of the property. It can be string, int, float, bool,
-		// color or file
+		// color, file, object or class
 		Type string `xml:"type,attr"`
+		// PropertyType is the name of the custom type of the property
+		PropertyType string `xml:"propertytype,attr"`
 		// Value is the value of the property
 		Value string `xml:"value,attr"`
+		// Properties are the members of a class property
+		Properties Properties `xml:"properties>property"`
 
 		CharData string `xml:",chardata"`
 	}{}
+	line := currentLine(d)
 	if err := d.DecodeElement(&prop, &start); err != nil {
-		return err
+		return errorAt(err, elementPath("property", prop.Name), line)
 	}
 
 	p.Name = prop.Name
 	p.Type = prop.Type
+	p.PropertyType = prop.PropertyType
 	p.Value = prop.Value
-	if len(p.Value) == 0 {
+	p.Properties = prop.Properties
+	if len(p.Value) == 0 && len(p.Properties) == 0 {
 		p.Value = prop.CharData
 	}
 
 	return nil
 
 }
+
+// MarshalXML implements the encoding/xml Marshaler interface. Values that span
+// several lines are written as character data, like Tiled does.
+func (p Property) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	a := attrs{}
+	a.add("name", p.Name)
+	a.str("type", p.Type, "")
+	a.str("propertytype", p.PropertyType, "")
+	multiline := strings.Contains(p.Value, "\n")
+	if !multiline && (p.Type != "class" || p.Value != "") {
+		a.add("value", p.Value)
+	}
+	start, err := encodeStart(e, start, a)
+	if err != nil {
+		return err
+	}
+	if multiline {
+		if err = e.EncodeToken(xml.CharData(p.Value)); err != nil {
+			return err
+		}
+	}
+	if err = encodeProperties(e, p.Properties); err != nil {
+		return err
+	}
+	return e.EncodeToken(start.End())
+}
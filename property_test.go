@@ -0,0 +1,135 @@
+package tmx
+
+import (
+	"bytes"
+	"image/color"
+	"os"
+	"testing"
+)
+
+func TestPropertyTyped(t *testing.T) {
+	m, err := ParseFS(os.DirFS("testData"), "typedProperties.tmx")
+	if err != nil {
+		t.Errorf("Unable to parse typedProperties.tmx. Error was: %v", err)
+		return
+	}
+	checkTypedProperties(t, m)
+}
+
+func checkTypedProperties(t *testing.T, m Map) {
+	get := func(props Properties, name string) Property {
+		p, ok := props.Get(name)
+		if !ok {
+			t.Errorf("Property %v not found", name)
+		}
+		return p
+	}
+	if f, err := get(m.Properties, "gravity").Float(); err != nil || f != 9.81 {
+		t.Errorf("Float property not properly parsed\nWanted: %v\nGot: %v %v", 9.81, f, err)
+	}
+	if i, err := get(m.Properties, "lives").Int(); err != nil || i != 3 {
+		t.Errorf("Int property not properly parsed\nWanted: %v\nGot: %v %v", 3, i, err)
+	}
+	if b, err := get(m.Properties, "hardcore").Bool(); err != nil || !b {
+		t.Errorf("Bool property not properly parsed\nWanted: %v\nGot: %v %v", true, b, err)
+	}
+	if c, err := get(m.Properties, "tint").Color(); err != nil || c != (color.NRGBA{R: 0xff, A: 0x80}) {
+		t.Errorf("Color property not properly parsed\nWanted: %v\nGot: %v %v", color.NRGBA{R: 0xff, A: 0x80}, c, err)
+	}
+	if c, err := get(m.Properties, "sky").Color(); err != nil || c != (color.NRGBA{G: 0xff, A: 0xff}) {
+		t.Errorf("Color property not properly parsed\nWanted: %v\nGot: %v %v", color.NRGBA{G: 0xff, A: 0xff}, c, err)
+	}
+	if f := get(m.Properties, "music").File(); f != "audio/theme.ogg" {
+		t.Errorf("File property not properly resolved\nWanted: %v\nGot: %v", "audio/theme.ogg", f)
+	}
+	if id, err := get(m.Properties, "boss").Object(); err != nil || id != 2 {
+		t.Errorf("Object property not properly parsed\nWanted: %v\nGot: %v %v", 2, id, err)
+	}
+	if p := get(m.Properties, "difficulty"); p.PropertyType != "Difficulty" || p.Value != "Hard" {
+		t.Errorf("Enum property not properly parsed. Got: %+v", p)
+	}
+	spawn := get(m.Properties, "spawn")
+	if spawn.Type != "class" || spawn.PropertyType != "Spawn" {
+		t.Errorf("Class property not properly parsed. Got: %+v", spawn)
+	}
+	if i, err := get(spawn.Properties, "count").Int(); err != nil || i != 5 {
+		t.Errorf("Class member not properly parsed\nWanted: %v\nGot: %v %v", 5, i, err)
+	}
+	area := get(spawn.Properties, "area")
+	if f, err := get(area.Properties, "width").Float(); err != nil || f != 32.5 {
+		t.Errorf("Nested class member not properly parsed\nWanted: %v\nGot: %v %v", 32.5, f, err)
+	}
+	if f := get(m.Tilesets[0].Properties, "sheet").File(); f != "roguelikeHoliday_transparent.png" {
+		t.Errorf("File property not resolved relative to its tileset\nWanted: %v\nGot: %v", "roguelikeHoliday_transparent.png", f)
+	}
+	if p := get(m.Layers[0].Properties, "name"); p.Value != "ground" {
+		t.Errorf("Layer property not found with Get")
+	}
+	if _, ok := m.Properties.Get("notExist"); ok {
+		t.Errorf("Found a property that does not exist")
+	}
+}
+
+func TestPropertyTypedEncode(t *testing.T) {
+	_, m, _ := roundTrip(t, "typedProperties.tmx")
+	checkTypedProperties(t, m)
+}
+
+func TestPropertyTypedErrors(t *testing.T) {
+	p := Property{Value: "abc"}
+	if _, err := p.Int(); err == nil {
+		t.Errorf("Able to read %v as an int", p.Value)
+	}
+	if _, err := p.Float(); err == nil {
+		t.Errorf("Able to read %v as a float", p.Value)
+	}
+	if _, err := p.Bool(); err == nil {
+		t.Errorf("Able to read %v as a bool", p.Value)
+	}
+	if _, err := p.Color(); err == nil {
+		t.Errorf("Able to read %v as a color", p.Value)
+	}
+	if _, err := p.Object(); err == nil {
+		t.Errorf("Able to read %v as an object", p.Value)
+	}
+	if c, err := (Property{}).Color(); err != nil || c != (color.NRGBA{}) {
+		t.Errorf("Unset color was not transparent. Got: %v %v", c, err)
+	}
+}
+
+func TestPropertyClassJSON(t *testing.T) {
+	m, err := ParseJSON(bytes.NewReader([]byte(`{"properties":[
+ {"name":"spawn", "type":"class", "propertytype":"Spawn", "value":{"count":5, "speed":1.5, "flying":true, "label":"bat", "area":{"width":32}}},
+ {"name":"difficulty", "type":"string", "propertytype":"Difficulty", "value":"Hard"}
+]}`)))
+	if err != nil {
+		t.Errorf("Unable to parse class properties. Error was: %v", err)
+		return
+	}
+	spawn, _ := m.Properties.Get("spawn")
+	exp := []struct {
+		name, typ, value string
+	}{
+		{"area", "class", ""},
+		{"count", "int", "5"},
+		{"flying", "bool", "true"},
+		{"label", "string", "bat"},
+		{"speed", "float", "1.5"},
+	}
+	if len(spawn.Properties) != len(exp) {
+		t.Errorf("Wrong number of class members\nWanted: %v\nGot: %v", len(exp), len(spawn.Properties))
+		return
+	}
+	for i, e := range exp {
+		p := spawn.Properties[i]
+		if p.Name != e.name || p.Type != e.typ || p.Value != e.value {
+			t.Errorf("Class member not properly parsed\nWanted: %v\nGot: %+v", e, p)
+		}
+	}
+	if w, _ := spawn.Properties[0].Properties.Get("width"); w.Value != "32" {
+		t.Errorf("Nested class member not properly parsed\nWanted: %v\nGot: %v", "32", w.Value)
+	}
+	if d, _ := m.Properties.Get("difficulty"); d.PropertyType != "Difficulty" {
+		t.Errorf("Enum property type not properly parsed\nWanted: %v\nGot: %v", "Difficulty", d.PropertyType)
+	}
+}
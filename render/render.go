@@ -0,0 +1,363 @@
+// Package render draws Tiled maps onto images using only the standard library,
+// for thumbnails, previews and screenshots of levels without a game engine.
+package render
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"io/fs"
+	"math"
+
+	// Tileset images are usually PNGs, but Tiled can use any of these
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
+	"github.com/Noofbiz/tmx"
+)
+
+// Render draws the map onto a new image. Tileset and image layer images are
+// read from fsys at the locations given by tmx.Image.Path, so fsys should be
+// the file system the map was loaded from.
+//
+// Tile layers and image layers are drawn in the order they appear in the file,
+// with their opacity, visibility, offsets and tint colors, and those of the
+// groups they are in, combined as in tmx.Map.Effective. Tint colors that can't
+// be parsed are ignored. Image layers are repeated across the image if they
+// have RepeatX or RepeatY set. Parallax factors are ignored, as if the view is
+// centered on the map's parallax origin.
+//
+// Tiles are drawn in the map's RenderOrder, with their tileset's tile offset,
+// render size and fill mode, and their flipping flags. Tiles of image
+// collections are drawn from their own images. Object layers aren't drawn.
+// Only orthogonal maps are supported.
+//
+// The image covers the map's Width and Height in tiles. For infinite maps it
+// covers every chunk, and the top left of the image is the top left of the
+// top left chunk.
+func Render(m *tmx.Map, fsys fs.FS) (*image.RGBA, error) {
+	if m.Orientation != "" && m.Orientation != "orthogonal" {
+		return nil, errors.New("render: unsupported orientation " + m.Orientation)
+	}
+	r := renderer{
+		m:      m,
+		fsys:   fsys,
+		images: make(map[string]image.Image),
+	}
+	bounds := image.Rect(0, 0, m.Width, m.Height)
+	if m.Infinite != 0 {
+		bounds = tileBounds(m.LayerNodes())
+	}
+	r.origin = image.Pt(-bounds.Min.X*m.TileWidth, -bounds.Min.Y*m.TileHeight)
+	r.dst = image.NewRGBA(image.Rect(0, 0, bounds.Dx()*m.TileWidth, bounds.Dy()*m.TileHeight))
+	if m.BackgroundColor != "" {
+		bg, err := tmx.ParseColor(m.BackgroundColor)
+		if err != nil {
+			return nil, err
+		}
+		draw.Draw(r.dst, r.dst.Bounds(), image.NewUniform(bg), image.Point{}, draw.Src)
+	}
+	if err := r.layers(m.LayerNodes()); err != nil {
+		return nil, err
+	}
+	return r.dst, nil
+}
+
+// tileBounds returns the area covered by the tile layers, in tiles
+func tileBounds(nodes []tmx.LayerNode) image.Rectangle {
+	var b image.Rectangle
+	for _, n := range nodes {
+		switch l := n.(type) {
+		case *tmx.Layer:
+			b = b.Union(l.Bounds())
+		case *tmx.Group:
+			b = b.Union(tileBounds(l.LayerNodes()))
+		}
+	}
+	return b
+}
+
+type renderer struct {
+	m    *tmx.Map
+	fsys fs.FS
+	dst  *image.RGBA
+	// origin is where the map's 0,0 is on dst
+	origin image.Point
+	// images are the images loaded so far, by path
+	images map[string]image.Image
+}
+
+// layers draws the visible layers, with the attributes of the groups they're
+// in as given by tmx.Map.Effective
+func (r *renderer) layers(nodes []tmx.LayerNode) error {
+	for _, n := range nodes {
+		e, _ := r.m.Effective(n)
+		if !e.Visible {
+			continue
+		}
+		var err error
+		switch l := n.(type) {
+		case *tmx.Layer:
+			err = r.layer(l, e)
+		case *tmx.ImageLayer:
+			err = r.imageLayer(l, e)
+		case *tmx.Group:
+			err = r.layers(l.LayerNodes())
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *renderer) layer(l *tmx.Layer, e tmx.Effective) error {
+	mask := opacityMask(e.Opacity)
+	var err error
+	l.EachTile(r.m.RenderOrder, func(x, y int, t tmx.TileData) {
+		if err != nil {
+			return
+		}
+		info, ok := r.m.TileForGID(t.GID)
+		if !ok || info.Image == nil {
+			return
+		}
+		var src image.Image
+		if src, err = r.image(*info.Image); err != nil {
+			return
+		}
+		rect := info.Rect
+		if rect.Empty() {
+			// The image's size wasn't given in the file
+			rect = src.Bounds()
+		}
+		tile := flip(src, rect, t.Flipping)
+		w, h := info.Tileset.RenderSize(tile.Bounds(), r.m.TileWidth, r.m.TileHeight)
+		tile = tinted(scale(tile, int(math.Round(w)), int(math.Round(h))), e.Tint)
+		// Tiles are aligned to the bottom left of their cell, so tiles
+		// taller than the map's tiles reach up into the row above. Tiles
+		// drawn at the grid size are centered in their cell instead.
+		px := float64(x*r.m.TileWidth) + e.OffsetX
+		py := float64((y+1)*r.m.TileHeight) - h + e.OffsetY
+		if info.Tileset.TileRenderSize == "grid" {
+			px += (float64(r.m.TileWidth) - w) / 2
+			py -= (float64(r.m.TileHeight) - h) / 2
+		}
+		if len(info.Tileset.TileOffset) > 0 {
+			px += info.Tileset.TileOffset[0].X
+			py += info.Tileset.TileOffset[0].Y
+		}
+		r.draw(tile, px, py, mask)
+	})
+	return err
+}
+
+func (r *renderer) imageLayer(l *tmx.ImageLayer, e tmx.Effective) error {
+	mask := opacityMask(e.Opacity)
+	for _, i := range l.Images {
+		if i.Source == "" {
+			continue
+		}
+		src, err := r.image(i)
+		if err != nil {
+			return err
+		}
+		src = tinted(src, e.Tint)
+		size := src.Bounds().Size()
+		if size.X <= 0 || size.Y <= 0 {
+			continue
+		}
+		// Repeated images start from the copy that covers the top left of
+		// the image being drawn
+		x0, x1 := e.OffsetX, e.OffsetX
+		if l.RepeatX != 0 {
+			x0 = start(e.OffsetX, -float64(r.origin.X), size.X)
+			x1 = float64(r.dst.Bounds().Dx() - r.origin.X)
+		}
+		y0, y1 := e.OffsetY, e.OffsetY
+		if l.RepeatY != 0 {
+			y0 = start(e.OffsetY, -float64(r.origin.Y), size.Y)
+			y1 = float64(r.dst.Bounds().Dy() - r.origin.Y)
+		}
+		for y := y0; y <= y1; y += float64(size.Y) {
+			for x := x0; x <= x1; x += float64(size.X) {
+				r.draw(src, x, y, mask)
+			}
+		}
+	}
+	return nil
+}
+
+// start returns the first position at or before min of an image repeated
+// every size pixels from offset
+func start(offset, min float64, size int) float64 {
+	return offset - math.Ceil((offset-min)/float64(size))*float64(size)
+}
+
+// draw draws src with its top left at the pixel coordinates x, y of the map
+func (r *renderer) draw(src image.Image, x, y float64, mask image.Image) {
+	sb := src.Bounds()
+	min := r.origin.Add(image.Pt(int(math.Round(x)), int(math.Round(y))))
+	dr := image.Rectangle{Min: min, Max: min.Add(sb.Size())}
+	draw.DrawMask(r.dst, dr, src, sb.Min, mask, image.Point{}, draw.Over)
+}
+
+// image returns the decoded image, loading it the first time it's used
+func (r *renderer) image(i tmx.Image) (image.Image, error) {
+	p := i.Path()
+	if img, ok := r.images[p]; ok {
+		return img, nil
+	}
+	f, err := r.fsys.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, errors.New("render: decoding " + p + ": " + err.Error())
+	}
+	if i.Transparent != "" {
+		key, err := tmx.ParseColor(i.Transparent)
+		if err != nil {
+			return nil, err
+		}
+		img = &colorKey{Image: img, key: key}
+	}
+	r.images[p] = img
+	return img, nil
+}
+
+// opacityMask returns the mask to draw a layer with the opacity, or nil if it
+// is opaque
+func opacityMask(opacity float64) image.Image {
+	if opacity >= 1 {
+		return nil
+	}
+	if opacity < 0 {
+		opacity = 0
+	}
+	return image.NewUniform(color.Alpha16{A: uint16(opacity * 0xffff)})
+}
+
+// tinted returns src multiplied by the tint color, or src if the tint is white
+func tinted(src image.Image, tint color.NRGBA) image.Image {
+	if tint == (color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}) {
+		return src
+	}
+	return &tintedImage{Image: src, c: tint}
+}
+
+// tintedImage is an image multiplied by a color
+type tintedImage struct {
+	image.Image
+	c color.NRGBA
+}
+
+func (t *tintedImage) ColorModel() color.Model {
+	return color.NRGBAModel
+}
+
+func (t *tintedImage) At(x, y int) color.Color {
+	return tmx.MultiplyColor(color.NRGBAModel.Convert(t.Image.At(x, y)).(color.NRGBA), t.c)
+}
+
+// scale returns src scaled to w by h pixels, or src if it is already that size
+func scale(src image.Image, w, h int) image.Image {
+	if src.Bounds().Dx() == w && src.Bounds().Dy() == h {
+		return src
+	}
+	return &scaled{src: src, w: w, h: h}
+}
+
+// scaled is an image scaled with nearest neighbour sampling, like Tiled draws
+// scaled tiles when smoothing is off
+type scaled struct {
+	src  image.Image
+	w, h int
+}
+
+func (s *scaled) ColorModel() color.Model {
+	return s.src.ColorModel()
+}
+
+func (s *scaled) Bounds() image.Rectangle {
+	return image.Rect(0, 0, s.w, s.h)
+}
+
+func (s *scaled) At(x, y int) color.Color {
+	if !image.Pt(x, y).In(s.Bounds()) {
+		return color.Transparent
+	}
+	sb := s.src.Bounds()
+	return s.src.At(sb.Min.X+x*sb.Dx()/s.w, sb.Min.Y+y*sb.Dy()/s.h)
+}
+
+// colorKey is an image with one color made transparent
+type colorKey struct {
+	image.Image
+	key color.NRGBA
+}
+
+func (c *colorKey) ColorModel() color.Model {
+	return color.NRGBAModel
+}
+
+func (c *colorKey) At(x, y int) color.Color {
+	col := color.NRGBAModel.Convert(c.Image.At(x, y)).(color.NRGBA)
+	if col.R == c.key.R && col.G == c.key.G && col.B == c.key.B {
+		return color.NRGBA{}
+	}
+	return col
+}
+
+// flip returns the area r of src with the tile flipping flags applied
+func flip(src image.Image, r image.Rectangle, flags uint32) image.Image {
+	if flags == 0 {
+		if s, ok := src.(interface {
+			SubImage(image.Rectangle) image.Image
+		}); ok {
+			return s.SubImage(r)
+		}
+	}
+	return &flipped{src: src, r: r, flags: flags}
+}
+
+// flipped is an area of an image with Tiled's tile flipping applied. The
+// diagonal flip swaps the x and y axes and is done before the horizontal and
+// vertical flips.
+type flipped struct {
+	src   image.Image
+	r     image.Rectangle
+	flags uint32
+}
+
+func (f *flipped) ColorModel() color.Model {
+	return f.src.ColorModel()
+}
+
+func (f *flipped) Bounds() image.Rectangle {
+	if f.flags&tmx.DiagonalFlipFlag != 0 {
+		return image.Rect(0, 0, f.r.Dy(), f.r.Dx())
+	}
+	return image.Rect(0, 0, f.r.Dx(), f.r.Dy())
+}
+
+func (f *flipped) At(x, y int) color.Color {
+	b := f.Bounds()
+	if !image.Pt(x, y).In(b) {
+		return color.Transparent
+	}
+	if f.flags&tmx.HorizontalFlipFlag != 0 {
+		x = b.Dx() - 1 - x
+	}
+	if f.flags&tmx.VerticalFlipFlag != 0 {
+		y = b.Dy() - 1 - y
+	}
+	if f.flags&tmx.DiagonalFlipFlag != 0 {
+		x, y = y, x
+	}
+	return f.src.At(f.r.Min.X+x, f.r.Min.Y+y)
+}
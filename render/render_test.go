@@ -0,0 +1,188 @@
+package render
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"strconv"
+	"testing"
+	"testing/fstest"
+
+	"github.com/Noofbiz/tmx"
+)
+
+var (
+	red    = color.RGBA{R: 255, A: 255}
+	blue   = color.RGBA{B: 255, A: 255}
+	green  = color.RGBA{G: 255, A: 255}
+	yellow = color.RGBA{R: 255, G: 255, A: 255}
+)
+
+// tilesetPNG returns a tileset image of two 4x4 tiles with a margin of 1 and
+// a spacing of 2. The first tile is red with a green pixel at 1,0, the second
+// is blue.
+func tilesetPNG(t *testing.T) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, 12, 6))
+	for y := 1; y < 5; y++ {
+		for x := 1; x < 5; x++ {
+			img.Set(x, y, red)
+			img.Set(x+6, y, blue)
+		}
+	}
+	img.Set(2, 1, green)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("Unable to encode tileset image. Error was: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func render(t *testing.T, layers string) *image.RGBA {
+	fsys := fstest.MapFS{
+		"tiles.png": &fstest.MapFile{Data: tilesetPNG(t)},
+		"map.tmx": &fstest.MapFile{Data: []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<map version="1.2" orientation="orthogonal" width="3" height="2" tilewidth="4" tileheight="4" backgroundcolor="#ffff00">
+ <tileset firstgid="1" name="tiles" tilewidth="4" tileheight="4" spacing="2" margin="1" tilecount="2" columns="2">
+  <image source="tiles.png" width="12" height="6"/>
+ </tileset>
+` + layers + `
+</map>`)},
+	}
+	m, err := tmx.ParseFS(fsys, "map.tmx")
+	if err != nil {
+		t.Fatalf("Unable to parse map. Error was: %v", err)
+	}
+	img, err := Render(&m, fsys)
+	if err != nil {
+		t.Fatalf("Unable to render map. Error was: %v", err)
+	}
+	return img
+}
+
+func checkPixels(t *testing.T, img *image.RGBA, exp map[image.Point]color.RGBA) {
+	t.Helper()
+	for p, c := range exp {
+		if got := img.RGBAAt(p.X, p.Y); got != c {
+			t.Errorf("Pixel at %v was incorrect\nWanted: %v\nGot: %v", p, c, got)
+		}
+	}
+}
+
+func TestRenderTiles(t *testing.T) {
+	img := render(t, `<layer name="Ground" width="3" height="2"><data encoding="csv">1,2,0,0,0,1</data></layer>`)
+	if img.Bounds() != image.Rect(0, 0, 12, 8) {
+		t.Fatalf("Rendered image bounds were incorrect\nWanted: %v\nGot: %v", image.Rect(0, 0, 12, 8), img.Bounds())
+	}
+	checkPixels(t, img, map[image.Point]color.RGBA{
+		{0, 0}:  red,
+		{1, 0}:  green,
+		{5, 2}:  blue,
+		{9, 1}:  yellow,
+		{8, 4}:  red,
+		{9, 4}:  green,
+		{11, 7}: red,
+	})
+}
+
+func TestRenderFlips(t *testing.T) {
+	h, v, d := tmx.HorizontalFlipFlag|1, tmx.VerticalFlipFlag|1, tmx.DiagonalFlipFlag|1
+	img := render(t, `<layer name="Ground" width="3" height="2"><data encoding="csv">`+
+		strconv.FormatUint(uint64(h), 10)+`,`+strconv.FormatUint(uint64(v), 10)+`,`+strconv.FormatUint(uint64(d), 10)+`,0,0,0</data></layer>`)
+	checkPixels(t, img, map[image.Point]color.RGBA{
+		{2, 0}: green,
+		{1, 0}: red,
+		{5, 3}: green,
+		{5, 0}: red,
+		{8, 1}: green,
+		{9, 0}: red,
+	})
+}
+
+func TestRenderLayers(t *testing.T) {
+	img := render(t, `<layer name="Hidden" width="3" height="2" visible="0"><data encoding="csv">2,2,2,2,2,2</data></layer>
+ <group name="Group" offsetx="4" offsety="4" opacity="0.5">
+  <layer name="Ground" width="3" height="2" offsetx="-4"><data encoding="csv">2,0,0,0,0,0</data></layer>
+ </group>`)
+	got := img.RGBAAt(1, 5)
+	if got.B < 120 || got.B > 135 || got.R < 120 || got.R > 135 {
+		t.Errorf("Half transparent blue tile over yellow was incorrect\nGot: %v", got)
+	}
+	checkPixels(t, img, map[image.Point]color.RGBA{
+		{0, 0}: yellow,
+		{5, 5}: yellow,
+	})
+}
+
+func TestRenderUnsupportedOrientation(t *testing.T) {
+	m := tmx.Map{Orientation: "isometric"}
+	if _, err := Render(&m, fstest.MapFS{}); err == nil {
+		t.Errorf("Able to render an isometric map")
+	}
+}
+
+func TestRenderTint(t *testing.T) {
+	img := render(t, `<group name="Tinted" tintcolor="#ff00ff">
+ <layer name="Ground" width="3" height="2" tintcolor="#ffff00"><data encoding="csv">1,2,0,0,0,0</data></layer>
+</group>`)
+	black := color.RGBA{A: 255}
+	checkPixels(t, img, map[image.Point]color.RGBA{
+		{0, 0}:  red,
+		{1, 0}:  black,
+		{5, 2}:  black,
+		{10, 6}: yellow,
+	})
+}
+
+func TestRenderInvalidTint(t *testing.T) {
+	// Tint colors that can't be parsed are ignored, as in tmx.Map.Effective
+	img := render(t, `<group name="Tinted" tintcolor="#nothex">
+ <layer name="Ground" width="3" height="2"><data encoding="csv">1,2,0,0,0,0</data></layer>
+</group>`)
+	checkPixels(t, img, map[image.Point]color.RGBA{
+		{0, 0}: red,
+		{1, 0}: green,
+		{5, 2}: blue,
+	})
+}
+
+func TestRenderImageLayerRepeat(t *testing.T) {
+	img := render(t, `<imagelayer name="Background" offsetx="-6" repeatx="1"><image source="tiles.png" width="12" height="6"/></imagelayer>`)
+	checkPixels(t, img, map[image.Point]color.RGBA{
+		{1, 1}: blue,
+		{7, 1}: red,
+		{8, 1}: green,
+		{1, 7}: yellow,
+	})
+}
+
+func TestRenderImageCollection(t *testing.T) {
+	fsys := fstest.MapFS{
+		"tiles.png": &fstest.MapFile{Data: tilesetPNG(t)},
+		"map.tmx": &fstest.MapFile{Data: []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<map version="1.10" orientation="orthogonal" width="2" height="1" tilewidth="4" tileheight="4" backgroundcolor="#ffff00">
+ <tileset firstgid="1" name="collection" tilewidth="12" tileheight="6" tilecount="2" columns="0" tilerendersize="grid" fillmode="preserve-aspect-fit">
+  <tile id="0" x="7" y="1" width="4" height="4"><image source="tiles.png" width="12" height="6"/></tile>
+  <tile id="3"><image source="tiles.png" width="12" height="6"/></tile>
+ </tileset>
+ <layer name="Ground" width="2" height="1"><data encoding="csv">1,4</data></layer>
+</map>`)},
+	}
+	m, err := tmx.ParseFS(fsys, "map.tmx")
+	if err != nil {
+		t.Fatalf("Unable to parse map. Error was: %v", err)
+	}
+	img, err := Render(&m, fsys)
+	if err != nil {
+		t.Fatalf("Unable to render map. Error was: %v", err)
+	}
+	// The whole image is fitted into 4x2 pixels and centered in its cell
+	checkPixels(t, img, map[image.Point]color.RGBA{
+		{0, 0}: blue,
+		{3, 3}: blue,
+		{5, 0}: yellow,
+		{5, 2}: red,
+		{7, 2}: blue,
+		{5, 3}: yellow,
+	})
+}
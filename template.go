@@ -1,5 +1,7 @@
 package tmx
 
+import "encoding/xml"
+
 // Template is a separate file that contains the template root element, a
 // map object and a tileset element that points to an external tileset if
 // the object is a tile object
@@ -8,4 +10,20 @@ type Template struct {
 	Tilesets []Tileset `xml:"tileset"`
 	// Objects are the template objects
 	Objects []Object `xml:"object"`
+
+	// line is where the template was found in its file
+	line int
+}
+
+// UnmarshalXML implements the encoding/xml Unmarshaler interface
+func (t *Template) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type template Template
+	tmpl := template{
+		line: currentLine(d),
+	}
+	if err := d.DecodeElement(&tmpl, &start); err != nil {
+		return errorAt(err, "template", tmpl.line)
+	}
+	*t = (Template)(tmpl)
+	return nil
 }
@@ -0,0 +1,7 @@
+<?xml version="1.0" encoding="UTF-8"?>
+<tileset version="1.8" tiledversion="1.8.2" name="properties" tilewidth="16" tileheight="16" tilecount="1" columns="1">
+ <properties>
+  <property name="sheet" type="file" value="../roguelikeHoliday_transparent.png"/>
+ </properties>
+ <image source="../roguelikeHoliday_transparent.png" width="16" height="16"/>
+</tileset>
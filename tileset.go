@@ -2,9 +2,8 @@ package tmx
 
 import (
 	"encoding/xml"
-	"io/ioutil"
-	"os"
 	"path"
+	"strconv"
 )
 
 // Tileset is a tileset used for the map
@@ -28,6 +27,16 @@ type Tileset struct {
 	TileCount int `xml:"tilecount,attr,omitempty"`
 	// Columns is the number of tile columns in the tileset
 	Columns int `xml:"columns,attr,omitempty"`
+	// ObjectAlignment is the point of tile objects that their position refers
+	// to: unspecified (the default), topleft, top, topright, left, center,
+	// right, bottomleft, bottom or bottomright
+	ObjectAlignment string `xml:"objectalignment,attr"`
+	// TileRenderSize is the size tiles are drawn at: tile (the default) for
+	// the size of their image, or grid for the tile size of the map
+	TileRenderSize string `xml:"tilerendersize,attr"`
+	// FillMode is how tiles drawn at the grid size fill the grid when their
+	// aspect ratio is different: stretch (the default) or preserve-aspect-fit
+	FillMode string `xml:"fillmode,attr"`
 	// TileOffset is used to specify an offset in pixels, to be applied when
 	// drawing a tile from the related tileset. When not present, no offset
 	// is applied
@@ -35,16 +44,47 @@ type Tileset struct {
 	// Grid is is only used in case of isometric orientation, and determines how
 	// tile overlays for terrain and collision information are rendered
 	Grid []Grid `xml:"grid"`
+	// Transformations are the ways tiles of the tileset can be flipped or
+	// rotated to make variants of them when using wang sets
+	Transformations []Transformations `xml:"transformations"`
 	// Properties are the custom properties of the tileset
-	Properties []Property `xml:"properties,omitempty>property"`
+	Properties Properties `xml:"properties>property"`
 	// Image is the image associated with the tileset
 	Image []Image `xml:"image"`
 	// TerrainTypes are the terraintypes associated with the tileset
-	TerrainTypes []Terrain `xml:"terraintypes,omitempty>terrain"`
+	TerrainTypes []Terrain `xml:"terraintypes>terrain"`
 	// Tiles are tiles in the tileset
 	Tiles []Tile `xml:"tile"`
 	// WangSets contain the list of wang sets defined for this tileset
-	WangSets []WangSet `xml:"wangsets,omitempty>wangset"`
+	WangSets []WangSet `xml:"wangsets>wangset"`
+
+	// line is where the tileset was found in the file
+	line int
+}
+
+// UnmarshalXML implements the encoding/xml Unmarshaler interface
+func (t *Tileset) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type tileset Tileset
+	ts := tileset{
+		ObjectAlignment: "unspecified",
+		TileRenderSize:  "tile",
+		FillMode:        "stretch",
+		line:            currentLine(d),
+	}
+	if err := d.DecodeElement(&ts, &start); err != nil {
+		return errorAt(err, (*Tileset)(&ts).path(), ts.line)
+	}
+	*t = (Tileset)(ts)
+	return nil
+}
+
+// path returns the path segment of the tileset used in errors. Tilesets are
+// named by their name, or by their source before it is loaded.
+func (t *Tileset) path() string {
+	if t.Name == "" {
+		return elementPath("tileset", t.Source)
+	}
+	return elementPath("tileset", t.Name)
 }
 
 // TileOffset is used to specify an offset in pixels, to be applied when
@@ -69,6 +109,20 @@ type Grid struct {
 	Height float64 `xml:"height,attr"`
 }
 
+// Transformations are the ways tiles of a tileset can be flipped or rotated
+// by the terrain tools
+type Transformations struct {
+	// HFlip is whether tiles can be flipped horizontally (0 or 1)
+	HFlip int `xml:"hflip,attr"`
+	// VFlip is whether tiles can be flipped vertically (0 or 1)
+	VFlip int `xml:"vflip,attr"`
+	// Rotate is whether tiles can be rotated in 90-degree steps (0 or 1)
+	Rotate int `xml:"rotate,attr"`
+	// PreferUntransformed is whether untransformed tiles are used when they
+	// fit, rather than choosing between them and transformed tiles (0 or 1)
+	PreferUntransformed int `xml:"preferuntransformed,attr"`
+}
+
 // Image is data for an image file
 type Image struct {
 	// Format is used for embedded images, in combination with a data child element.
@@ -81,11 +135,31 @@ type Image struct {
 	// out without a # but this is planned to change.
 	Transparent string `xml:"trans,attr,omitempty"`
 	// Width is the image width in pixels
-	Width float64 `xml:"width,attr"`
+	Width float64 `xml:"width,attr,omitempty"`
 	// Height is the image height in pixels
-	Height float64 `xml:"height,attr"`
+	Height float64 `xml:"height,attr,omitempty"`
 	// Data is the image data
 	Data []Data `xml:"data"`
+
+	// file is the file the image was referenced from
+	file string
+}
+
+// Path returns the location of the image's source. If the image was loaded by
+// a Loader, it's resolved relative to the file that references it, within the
+// Loader's file system.
+func (i Image) Path() string {
+	if i.file == "" || i.Source == "" {
+		return i.Source
+	}
+	return path.Join(path.Dir(i.file), i.Source)
+}
+
+// setImageFile records the file the images were referenced from
+func setImageFile(images []Image, name string) {
+	for i := range images {
+		images[i].file = name
+	}
 }
 
 // Terrain is a terrain
@@ -102,6 +176,14 @@ type Tile struct {
 	ID uint32 `xml:"id,attr"`
 	// Type is the type of the tile
 	Type string `xml:"type,attr"`
+	// X and Y are the top left of the tile's sub-rectangle in its image, for
+	// tiles of image collection tilesets
+	X int `xml:"x,attr"`
+	Y int `xml:"y,attr"`
+	// Width and Height are the size of the tile's sub-rectangle in its image,
+	// for tiles of image collection tilesets. 0 means the size of the image.
+	Width  int `xml:"width,attr"`
+	Height int `xml:"height,attr"`
 	// Terrain defines the terrain type of each corner of the tile, given as
 	// comma-separated indexes in the terrain types array in the order top-left,
 	// top-right, bottom-left, bottom-right. Leaving out a value means that corner
@@ -109,9 +191,10 @@ type Tile struct {
 	Terrain string `xml:"terrain,attr"`
 	// Probability is a percentage indicating the probability that this tile is
 	// chosen when it competes with others while editing with the terrain tool.
+	// Defaults to 1.
 	Probability float64 `xml:"probability,attr"`
 	// Properties are the custom properties of the tile
-	Properties []Property `xml:"properties>property"`
+	Properties Properties `xml:"properties>property"`
 	// Image is the image associated with the tile
 	Image []Image `xml:"image"`
 	// ObjectGroups are a group of objects
@@ -129,40 +212,143 @@ type Frame struct {
 	Duration float64 `xml:"duration,attr"`
 }
 
-// UnmarshalXML implements the encoding/xml Unmarshaler interface
-func (t *Tileset) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
-	type tileset Tileset
-	ts := tileset{}
-	if err := d.DecodeElement(&ts, &start); err != nil {
+// MarshalXML implements the encoding/xml Marshaler interface. External
+// tilesets are written as a reference to their source.
+func (t Tileset) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	a := attrs{}
+	a.uint("firstgid", t.FirstGID, 0)
+	if t.Source != "" {
+		a.add("source", t.Source)
+		return encodeEmpty(e, start, a)
+	}
+	a.add("name", t.Name)
+	a.add("tilewidth", strconv.Itoa(t.TileWidth))
+	a.add("tileheight", strconv.Itoa(t.TileHeight))
+	a.int("spacing", t.Spacing, 0)
+	a.float("margin", t.Margin, 0)
+	a.int("tilecount", t.TileCount, 0)
+	a.int("columns", t.Columns, 0)
+	a.str("objectalignment", t.ObjectAlignment, "unspecified")
+	a.str("tilerendersize", t.TileRenderSize, "tile")
+	a.str("fillmode", t.FillMode, "stretch")
+	start, err := encodeStart(e, start, a)
+	if err != nil {
 		return err
 	}
-	*t = (Tileset)(ts)
-	if t.Source != "" {
-		t2 := Tileset{}
-		f, err := os.Open(path.Join(path.Dir(TMXURL), t.Source))
-		defer f.Close()
-		if err != nil {
+	for _, to := range t.TileOffset {
+		if err = e.EncodeElement(to, startElement("tileoffset")); err != nil {
+			return err
+		}
+	}
+	for _, g := range t.Grid {
+		if err = e.EncodeElement(g, startElement("grid")); err != nil {
+			return err
+		}
+	}
+	for _, tr := range t.Transformations {
+		if err = e.EncodeElement(tr, startElement("transformations")); err != nil {
+			return err
+		}
+	}
+	if err = encodeProperties(e, t.Properties); err != nil {
+		return err
+	}
+	for _, i := range t.Image {
+		if err = e.EncodeElement(i, startElement("image")); err != nil {
+			return err
+		}
+	}
+	if len(t.TerrainTypes) > 0 {
+		ts := startElement("terraintypes")
+		if err = e.EncodeToken(ts); err != nil {
+			return err
+		}
+		for _, tt := range t.TerrainTypes {
+			if err = e.EncodeElement(tt, startElement("terrain")); err != nil {
+				return err
+			}
+		}
+		if err = e.EncodeToken(ts.End()); err != nil {
 			return err
 		}
-		b, _ := ioutil.ReadAll(f)
-		err = xml.Unmarshal(b, &t2)
-		if err != nil {
+	}
+	for _, tile := range t.Tiles {
+		if err = e.EncodeElement(tile, startElement("tile")); err != nil {
+			return err
+		}
+	}
+	if len(t.WangSets) > 0 {
+		ws := startElement("wangsets")
+		if err = e.EncodeToken(ws); err != nil {
+			return err
+		}
+		for _, w := range t.WangSets {
+			if err = e.EncodeElement(w, startElement("wangset")); err != nil {
+				return err
+			}
+		}
+		if err = e.EncodeToken(ws.End()); err != nil {
 			return err
 		}
-		t.Name = t2.Name
-		t.TileWidth = t2.TileWidth
-		t.TileHeight = t2.TileHeight
-		t.Spacing = t2.Spacing
-		t.Margin = t2.Margin
-		t.TileCount = t2.TileCount
-		t.Columns = t2.Columns
-		t.TileOffset = t2.TileOffset
-		t.Grid = t2.Grid
-		t.Image = t2.Image
-		t.Properties = t2.Properties
-		t.TerrainTypes = t2.TerrainTypes
-		t.Tiles = t2.Tiles
-		t.WangSets = t2.WangSets
 	}
+	return e.EncodeToken(start.End())
+}
+
+// UnmarshalXML implements the encoding/xml Unmarshaler interface
+func (t *Tile) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type tile Tile
+	ti := tile{
+		Probability: 1,
+	}
+	line := currentLine(d)
+	if err := d.DecodeElement(&ti, &start); err != nil {
+		return errorAt(err, elementPath("tile", strconv.FormatUint(uint64(ti.ID), 10)), line)
+	}
+	*t = (Tile)(ti)
 	return nil
 }
+
+// MarshalXML implements the encoding/xml Marshaler interface
+func (t Tile) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	a := attrs{}
+	a.add("id", strconv.FormatUint(uint64(t.ID), 10))
+	a.str("type", t.Type, "")
+	a.int("x", t.X, 0)
+	a.int("y", t.Y, 0)
+	a.int("width", t.Width, 0)
+	a.int("height", t.Height, 0)
+	a.str("terrain", t.Terrain, "")
+	a.float("probability", t.Probability, 1)
+	start, err := encodeStart(e, start, a)
+	if err != nil {
+		return err
+	}
+	if err = encodeProperties(e, t.Properties); err != nil {
+		return err
+	}
+	for _, i := range t.Image {
+		if err = e.EncodeElement(i, startElement("image")); err != nil {
+			return err
+		}
+	}
+	for _, og := range t.ObjectGroup {
+		if err = e.EncodeElement(og, startElement("objectgroup")); err != nil {
+			return err
+		}
+	}
+	if len(t.AnimationFrames) > 0 {
+		as := startElement("animation")
+		if err = e.EncodeToken(as); err != nil {
+			return err
+		}
+		for _, f := range t.AnimationFrames {
+			if err = e.EncodeElement(f, startElement("frame")); err != nil {
+				return err
+			}
+		}
+		if err = e.EncodeToken(as.End()); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
@@ -0,0 +1,241 @@
+package tmx
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// PropertyError is the error returned by UnmarshalProperties when a property
+// can't be stored in its field, or a required property is missing
+type PropertyError struct {
+	// Name is the name of the property. Members of class properties are
+	// prefixed with the names of their classes, such as "spawn.area.width".
+	Name string
+	// Err is the reason the property couldn't be stored
+	Err error
+}
+
+func (e *PropertyError) Error() string {
+	return "tmx: property " + strconv.Quote(e.Name) + ": " + e.Err.Error()
+}
+
+// Unwrap returns the reason the property couldn't be stored
+func (e *PropertyError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	// ErrMissingProperty is the reason given when a required property is missing
+	ErrMissingProperty = errors.New("required property is missing")
+	// ErrPropertyType is the reason given when the type of a property doesn't
+	// match the type of its field
+	ErrPropertyType = errors.New("property type does not match field")
+)
+
+var colorType = reflect.TypeOf(color.NRGBA{})
+
+// UnmarshalProperties stores the properties in the struct pointed to by v.
+// Each exported field is filled from the property named by its tmx tag, or by
+// the field name, ignoring case, if it has no tag. A tag of "-" skips the
+// field, and the option "required" reports an error if the property is
+// missing. The fields of embedded structs without a tag are filled as if they
+// were fields of the outer struct:
+//
+//	type Spawn struct {
+//		Enemy string  `tmx:"enemy,required"`
+//		Count int     `tmx:"count"`
+//		Area  Area    `tmx:"area"`
+//		Speed float64 `tmx:"speed"`
+//	}
+//
+// Properties are converted according to their Type. String fields take any
+// property that isn't a class, with file properties resolved by Property.File.
+// Int and uint fields take int and object properties, float fields take float
+// and int properties, bool fields take bool properties and color.NRGBA
+// fields take color properties. Color fields also take string properties
+// holding a color, since that's how members of class properties read from
+// Tiled's JSON format come. Struct fields, and pointers to them, take class
+// properties and are filled from their members. Any property that can't be
+// stored is reported as a *PropertyError.
+func UnmarshalProperties(props []Property, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("tmx: UnmarshalProperties needs a non-nil pointer to a struct")
+	}
+	return unmarshalProperties(props, rv.Elem(), "")
+}
+
+// Unmarshal stores the properties in the struct pointed to by v. See
+// UnmarshalProperties.
+func (ps Properties) Unmarshal(v interface{}) error {
+	return UnmarshalProperties(ps, v)
+}
+
+func unmarshalProperties(props Properties, rv reflect.Value, prefix string) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		tag, tagged := f.Tag.Lookup("tmx")
+		if f.Anonymous && !tagged {
+			// The fields of embedded structs are filled as if they were
+			// fields of the outer struct
+			if ev, ok := embedded(rv.Field(i)); ok {
+				if err := unmarshalProperties(props, ev, prefix); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if f.PkgPath != "" || tag == "-" {
+			continue
+		}
+		name, required := f.Name, false
+		if tagged {
+			opts := strings.Split(tag, ",")
+			if opts[0] != "" {
+				name = opts[0]
+			}
+			for _, o := range opts[1:] {
+				if o == "required" {
+					required = true
+				}
+			}
+		}
+		p, ok := props.Get(name)
+		if !ok && !tagged {
+			p, ok = props.getFold(name)
+		}
+		if !ok {
+			if required {
+				return &PropertyError{Name: prefix + name, Err: ErrMissingProperty}
+			}
+			continue
+		}
+		if err := setProperty(p, rv.Field(i), prefix+name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// embedded returns the struct of an embedded field, allocating it if the
+// field is a nil pointer that can be set. It returns false if the field isn't
+// a struct or a pointer to one, or is a nil pointer to an unexported type.
+func embedded(v reflect.Value) (reflect.Value, bool) {
+	if v.Kind() == reflect.Ptr {
+		if v.Type().Elem().Kind() != reflect.Struct {
+			return v, false
+		}
+		if v.IsNil() {
+			if !v.CanSet() {
+				return v, false
+			}
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	return v, v.Kind() == reflect.Struct
+}
+
+// getFold returns the property with the name, ignoring case
+func (ps Properties) getFold(name string) (Property, bool) {
+	for _, p := range ps {
+		if strings.EqualFold(p.Name, name) {
+			return p, true
+		}
+	}
+	return Property{}, false
+}
+
+// setProperty stores the property in the field
+func setProperty(p Property, field reflect.Value, name string) error {
+	typ := p.Type
+	if typ == "" {
+		typ = "string"
+	}
+	mismatch := &PropertyError{
+		Name: name,
+		Err:  fmt.Errorf("%w: cannot store %v property in %v", ErrPropertyType, typ, field.Type()),
+	}
+	if field.Type() == colorType {
+		if typ != "color" && typ != "string" {
+			return mismatch
+		}
+		c, err := p.Color()
+		if err != nil {
+			if typ == "string" {
+				return mismatch
+			}
+			return &PropertyError{Name: name, Err: err}
+		}
+		field.Set(reflect.ValueOf(c))
+		return nil
+	}
+	var err error
+	switch field.Kind() {
+	case reflect.String:
+		if typ == "class" {
+			return mismatch
+		}
+		if typ == "file" {
+			field.SetString(p.File())
+		} else {
+			field.SetString(p.Value)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if typ != "int" && typ != "object" {
+			return mismatch
+		}
+		var i int64
+		if i, err = strconv.ParseInt(p.Value, 10, field.Type().Bits()); err == nil {
+			field.SetInt(i)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if typ != "int" && typ != "object" {
+			return mismatch
+		}
+		var u uint64
+		if u, err = strconv.ParseUint(p.Value, 10, field.Type().Bits()); err == nil {
+			field.SetUint(u)
+		}
+	case reflect.Float32, reflect.Float64:
+		if typ != "float" && typ != "int" {
+			return mismatch
+		}
+		var f float64
+		if f, err = strconv.ParseFloat(p.Value, field.Type().Bits()); err == nil {
+			field.SetFloat(f)
+		}
+	case reflect.Bool:
+		if typ != "bool" {
+			return mismatch
+		}
+		var b bool
+		if b, err = p.Bool(); err == nil {
+			field.SetBool(b)
+		}
+	case reflect.Struct:
+		if typ != "class" {
+			return mismatch
+		}
+		return unmarshalProperties(p.Properties, field, name+".")
+	case reflect.Ptr:
+		if field.Type().Elem().Kind() != reflect.Struct || typ != "class" {
+			return mismatch
+		}
+		if field.IsNil() {
+			field.Set(reflect.New(field.Type().Elem()))
+		}
+		return unmarshalProperties(p.Properties, field.Elem(), name+".")
+	default:
+		return mismatch
+	}
+	if err != nil {
+		return &PropertyError{Name: name, Err: err}
+	}
+	return nil
+}
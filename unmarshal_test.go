@@ -0,0 +1,175 @@
+package tmx
+
+import (
+	"errors"
+	"image/color"
+	"os"
+	"strings"
+	"testing"
+)
+
+type testArea struct {
+	Width float64 `tmx:"width"`
+}
+
+type testSpawn struct {
+	Count int       `tmx:"count,required"`
+	Area  *testArea `tmx:"area"`
+}
+
+type testLevel struct {
+	Gravity    float64     `tmx:"gravity"`
+	Lives      uint8       `tmx:"lives"`
+	Hardcore   bool        `tmx:"hardcore"`
+	Tint       color.NRGBA `tmx:"tint"`
+	Music      string      `tmx:"music"`
+	Boss       uint32      `tmx:"boss"`
+	Difficulty string      `tmx:"difficulty"`
+	Spawn      testSpawn   `tmx:"spawn"`
+	Skipped    string      `tmx:"-"`
+	NotThere   int         `tmx:"notThere"`
+	unexported int
+}
+
+func TestUnmarshalProperties(t *testing.T) {
+	m, err := ParseFS(os.DirFS("testData"), "typedProperties.tmx")
+	if err != nil {
+		t.Errorf("Unable to parse typedProperties.tmx. Error was: %v", err)
+		return
+	}
+	l := testLevel{Skipped: "kept"}
+	if err = UnmarshalProperties(m.Properties, &l); err != nil {
+		t.Errorf("Unable to unmarshal properties. Error was: %v", err)
+		return
+	}
+	if l.Gravity != 9.81 || l.Lives != 3 || !l.Hardcore || l.Boss != 2 {
+		t.Errorf("Properties not properly unmarshalled. Got: %+v", l)
+	}
+	if l.Tint != (color.NRGBA{R: 0xff, A: 0x80}) {
+		t.Errorf("Color property not properly unmarshalled. Got: %v", l.Tint)
+	}
+	if l.Music != "audio/theme.ogg" || l.Difficulty != "Hard" || l.Skipped != "kept" {
+		t.Errorf("String properties not properly unmarshalled. Got: %+v", l)
+	}
+	if l.Spawn.Count != 5 || l.Spawn.Area == nil || l.Spawn.Area.Width != 32.5 {
+		t.Errorf("Class properties not properly unmarshalled. Got: %+v", l.Spawn)
+	}
+	layer := struct {
+		Name string
+	}{}
+	if err = m.Layers[0].Properties.Unmarshal(&layer); err != nil || layer.Name != "ground" {
+		t.Errorf("Layer properties not properly unmarshalled. Got: %v %v", layer.Name, err)
+	}
+}
+
+func TestUnmarshalPropertiesErrors(t *testing.T) {
+	props := Properties{
+		Property{Name: "count", Type: "int", Value: "many"},
+		Property{Name: "flag", Type: "bool", Value: "true"},
+		Property{Name: "spawn", Type: "class", Properties: Properties{
+			Property{Name: "area", Type: "class"},
+		}},
+	}
+	tests := []struct {
+		v    interface{}
+		name string
+		err  error
+	}{
+		{&struct {
+			Count int `tmx:"count"`
+		}{}, "count", nil},
+		{&struct {
+			Flag int `tmx:"flag"`
+		}{}, "flag", ErrPropertyType},
+		{&struct {
+			Flag string `tmx:"flag"`
+		}{}, "", nil},
+		{&struct {
+			Spawn string `tmx:"spawn"`
+		}{}, "spawn", ErrPropertyType},
+		{&struct {
+			Missing int `tmx:"missing,required"`
+		}{}, "missing", ErrMissingProperty},
+		{&struct {
+			Spawn testSpawn `tmx:"spawn"`
+		}{}, "spawn.count", ErrMissingProperty},
+		{&struct {
+			Missing int `tmx:"missing,omitempty,required"`
+		}{}, "missing", ErrMissingProperty},
+		{&struct {
+			Missing int `tmx:",required"`
+		}{}, "Missing", ErrMissingProperty},
+		{&struct {
+			testSpawn
+		}{}, "count", nil},
+	}
+	for _, test := range tests {
+		err := UnmarshalProperties(props, test.v)
+		if test.name == "" {
+			if err != nil {
+				t.Errorf("Unable to unmarshal into %T. Error was: %v", test.v, err)
+			}
+			continue
+		}
+		var perr *PropertyError
+		if !errors.As(err, &perr) {
+			t.Errorf("Unmarshalling into %T did not return a *PropertyError. Got: %v", test.v, err)
+			continue
+		}
+		if perr.Name != test.name {
+			t.Errorf("Wrong property name in error\nWanted: %v\nGot: %v", test.name, perr.Name)
+		}
+		if test.err != nil && !errors.Is(err, test.err) {
+			t.Errorf("Wrong error reason\nWanted: %v\nGot: %v", test.err, err)
+		}
+	}
+	if err := UnmarshalProperties(props, struct{}{}); err == nil {
+		t.Errorf("Able to unmarshal into a value that isn't a pointer")
+	}
+}
+
+type TestNamed struct {
+	Name string `tmx:"name"`
+}
+
+func TestUnmarshalPropertiesEmbedded(t *testing.T) {
+	props := Properties{
+		Property{Name: "name", Value: "Goblin"},
+		Property{Name: "width", Type: "float", Value: "12"},
+		Property{Name: "count", Type: "int", Value: "3"},
+	}
+	v := struct {
+		*TestNamed
+		testArea
+		Spawn testSpawn `tmx:"-"`
+		testSpawn
+	}{}
+	if err := UnmarshalProperties(props, &v); err != nil {
+		t.Fatalf("Unable to unmarshal into embedded structs. Error was: %v", err)
+	}
+	if v.TestNamed == nil || v.Name != "Goblin" || v.Width != 12 || v.Count != 3 {
+		t.Errorf("Embedded structs not properly unmarshalled. Got: %+v %+v", v.TestNamed, v)
+	}
+}
+
+func TestUnmarshalPropertiesJSONClass(t *testing.T) {
+	m, err := ParseJSON(strings.NewReader(`{"orientation":"orthogonal","width":1,"height":1,"tilewidth":16,"tileheight":16,"layers":[],
+ "properties":[{"name":"spawn","type":"class","propertytype":"Spawn","value":{"tint":"#80ff0000","enemy":"goblin"}}]}`))
+	if err != nil {
+		t.Fatalf("Unable to parse JSON map. Error was: %v", err)
+	}
+	v := struct {
+		Spawn struct {
+			Tint  color.NRGBA `tmx:"tint"`
+			Enemy color.NRGBA `tmx:"enemy"`
+		} `tmx:"spawn"`
+	}{}
+	err = m.Properties.Unmarshal(&v)
+	if v.Spawn.Tint != (color.NRGBA{R: 0xff, A: 0x80}) {
+		t.Errorf("Color member of a JSON class not properly unmarshalled. Got: %v", v.Spawn.Tint)
+	}
+	var perr *PropertyError
+	if !errors.As(err, &perr) || perr.Name != "spawn.enemy" || !errors.Is(err, ErrPropertyType) {
+		t.Errorf("String member that isn't a color was stored in a color field. Got: %v", err)
+	}
+}
@@ -0,0 +1,113 @@
+package tmx
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Validate checks that the map's tile layer data can be used safely. Every
+// tile layer must hold Width*Height tiles, every chunk Width*Height tiles, and
+// every tile or tile object must refer to a global tile ID in the range of one
+// of the map's tilesets. Tilesets whose range isn't known, because they have
+// no TileCount, are taken to hold every ID from their first GID up to the next
+// tileset's. Each problem is returned as a *ValidationError.
+func (m *Map) Validate() []error {
+	v := validator{ranges: gidRanges(m.Tilesets)}
+	v.layers(m.Layers, m.ObjectGroups, m.Groups, "map")
+	return v.errs
+}
+
+// gidRange is the range of global tile IDs of a tileset. last is 0 if the
+// range isn't known.
+type gidRange struct {
+	first, last uint32
+}
+
+// gidRanges returns the ranges of the tilesets, sorted by their first GID
+func gidRanges(tilesets []Tileset) []gidRange {
+	ranges := make([]gidRange, len(tilesets))
+	for i := range tilesets {
+		t := &tilesets[i]
+		ranges[i].first = t.FirstGID
+		if n := t.tileRange(); n > 0 {
+			ranges[i].last = t.FirstGID + n - 1
+		}
+	}
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].first < ranges[j].first
+	})
+	return ranges
+}
+
+// validator collects the problems found in a map
+type validator struct {
+	ranges []gidRange
+	errs   []error
+}
+
+// outOfRange returns why gid isn't in the range of any of the map's
+// tilesets, or "" if it is or it's 0, which is an empty tile
+func (v *validator) outOfRange(gid uint32) string {
+	if gid == 0 {
+		return ""
+	}
+	if len(v.ranges) == 0 {
+		return fmt.Sprintf("gid %v with no tilesets", gid)
+	}
+	i := sort.Search(len(v.ranges), func(i int) bool {
+		return v.ranges[i].first > gid
+	})
+	if i == 0 {
+		return fmt.Sprintf("gid %v is below %v", gid, v.ranges[0].first)
+	}
+	if last := v.ranges[i-1].last; last > 0 && gid > last {
+		return fmt.Sprintf("gid %v is above %v", gid, last)
+	}
+	return ""
+}
+
+func (v *validator) add(path string, line int, err error) {
+	v.errs = append(v.errs, &ValidationError{Path: path, Line: line, Err: err})
+}
+
+func (v *validator) layers(layers []Layer, objectGroups []ObjectGroup, groups []Group, parent string) {
+	for _, l := range layers {
+		p := joinPath(parent, elementPath("layer", l.Name))
+		for _, da := range l.Data {
+			dp := joinPath(p, "data")
+			if len(da.Chunks) == 0 {
+				v.tiles(da.Tiles, l.Width, l.Height, dp, da.line)
+			}
+			for i := range da.Chunks {
+				c := &da.Chunks[i]
+				v.tiles(c.Tiles, c.Width, c.Height, joinPath(dp, c.path()), da.line)
+			}
+		}
+	}
+	for _, og := range objectGroups {
+		p := joinPath(parent, elementPath("objectgroup", og.Name))
+		for i := range og.Objects {
+			o := &og.Objects[i]
+			gid, _ := decodeGID(o.GID)
+			if r := v.outOfRange(gid); r != "" {
+				v.add(joinPath(p, o.path()), o.line, fmt.Errorf("%w: %v", ErrGIDRange, r))
+			}
+		}
+	}
+	for _, g := range groups {
+		v.layers(g.Layers, g.ObjectGroups, g.Group, joinPath(parent, elementPath("group", g.Name)))
+	}
+}
+
+// tiles checks the tiles of a layer or chunk with the size width x height
+func (v *validator) tiles(tiles []TileData, width, height int, path string, line int) {
+	if len(tiles) != width*height {
+		v.add(path, line, fmt.Errorf("%w: got %v tiles, wanted %v for %vx%v", ErrTileCount, len(tiles), width*height, width, height))
+	}
+	for i, t := range tiles {
+		if r := v.outOfRange(t.GID); r != "" {
+			v.add(path, line, fmt.Errorf("%w: tile %v has %v", ErrGIDRange, i, r))
+			return
+		}
+	}
+}
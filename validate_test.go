@@ -0,0 +1,117 @@
+package tmx
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestValidateTileCount(t *testing.T) {
+	m, err := ParseFS(os.DirFS("testData"), "csvData.tmx")
+	if err != nil {
+		t.Fatalf("Unable to parse csvData.tmx leniently. Error was: %v", err)
+	}
+	if len(m.Warnings) != 1 {
+		t.Fatalf("Wrong number of warnings\nWanted: %v\nGot: %v", 1, m.Warnings)
+	}
+	var ve *ValidationError
+	if !errors.As(m.Warnings[0], &ve) || !errors.Is(ve, ErrTileCount) {
+		t.Fatalf("Warning was not a tile count ValidationError\nGot: %v", m.Warnings[0])
+	}
+	if ve.Path != "map/layer[Tile Layer 1]/data" || ve.File != "csvData.tmx" || ve.Line != 7 {
+		t.Errorf("Warning position was incorrect\nWanted: %v:%v %v\nGot: %v:%v %v", "csvData.tmx", 7, "map/layer[Tile Layer 1]/data", ve.File, ve.Line, ve.Path)
+	}
+}
+
+func TestValidateChunkTileCount(t *testing.T) {
+	m, err := ParseFS(os.DirFS("testData"), "chunkData.tmx")
+	if err != nil {
+		t.Fatalf("Unable to parse chunkData.tmx leniently. Error was: %v", err)
+	}
+	if len(m.Warnings) != 1 || !errors.Is(m.Warnings[0], ErrTileCount) {
+		t.Fatalf("Wrong warnings for chunk\nWanted: %v\nGot: %v", ErrTileCount, m.Warnings)
+	}
+	var ve *ValidationError
+	errors.As(m.Warnings[0], &ve)
+	if ve.Path != "map/layer[Tile Layer 1]/data/chunk(-32,-16)" {
+		t.Errorf("Warning path was incorrect\nWanted: %v\nGot: %v", "map/layer[Tile Layer 1]/data/chunk(-32,-16)", ve.Path)
+	}
+}
+
+func TestValidateGIDRange(t *testing.T) {
+	m, err := ParseFS(os.DirFS("testData"), "gidOutOfRange.tmx")
+	if err != nil {
+		t.Fatalf("Unable to parse gidOutOfRange.tmx leniently. Error was: %v", err)
+	}
+	exp := []string{"map/layer[Tile Layer 1]/data", "map/objectgroup[Object Layer 1]/object[1]"}
+	if len(m.Warnings) != len(exp) {
+		t.Fatalf("Wrong number of warnings\nWanted: %v\nGot: %v", len(exp), m.Warnings)
+	}
+	for i, w := range m.Warnings {
+		var ve *ValidationError
+		if !errors.As(w, &ve) || !errors.Is(w, ErrGIDRange) {
+			t.Errorf("Warning was not a GID range ValidationError\nGot: %v", w)
+			continue
+		}
+		if ve.Path != exp[i] {
+			t.Errorf("Warning path was incorrect\nWanted: %v\nGot: %v", exp[i], ve.Path)
+		}
+	}
+}
+
+func TestValidateStrict(t *testing.T) {
+	l := NewLoader(os.DirFS("testData"))
+	l.Strict = true
+	if _, err := l.Parse("csvData.tmx"); !errors.Is(err, ErrTileCount) {
+		t.Errorf("Strict loader did not fail on a short layer\nWanted: %v\nGot: %v", ErrTileCount, err)
+	}
+	m, err := l.Parse("zlibData.tmx")
+	if err != nil {
+		t.Errorf("Strict loader failed on a valid map. Error was: %v", err)
+	}
+	if len(m.Warnings) != 0 {
+		t.Errorf("Valid map has warnings\nGot: %v", m.Warnings)
+	}
+}
+
+func TestValidateGIDRangeUnknownTileCount(t *testing.T) {
+	m := Map{
+		Tilesets: []Tileset{
+			Tileset{FirstGID: 10, Name: "unknown", Image: []Image{Image{Source: "tiles.png"}}},
+			Tileset{FirstGID: 1, Name: "known", TileCount: 4},
+		},
+		Layers: []Layer{Layer{Name: "Ground", Width: 3, Height: 1, Data: []Data{Data{
+			Tiles: []TileData{{GID: 4}, {GID: 50}, {GID: 1}},
+		}}}},
+		ObjectGroups: []ObjectGroup{ObjectGroup{Name: "Objects", Objects: []Object{{ID: 1, GID: 6}}}},
+	}
+	warnings := m.Validate()
+	if len(warnings) != 1 || !errors.Is(warnings[0], ErrGIDRange) {
+		t.Fatalf("Wrong warnings with a tileset of unknown size\nWanted: %v\nGot: %v", ErrGIDRange, warnings)
+	}
+	var ve *ValidationError
+	errors.As(warnings[0], &ve)
+	if ve.Path != "map/objectgroup[Objects]/object[1]" {
+		t.Errorf("Warning path was incorrect\nWanted: %v\nGot: %v", "map/objectgroup[Objects]/object[1]", ve.Path)
+	}
+}
+
+func TestValidateGIDBelowTilesets(t *testing.T) {
+	layer := Layer{Name: "Ground", Width: 2, Height: 1, Data: []Data{Data{
+		Tiles: []TileData{{GID: 0}, {GID: 2}},
+	}}}
+	tests := []struct {
+		name     string
+		tilesets []Tileset
+	}{
+		{"below the first tileset", []Tileset{Tileset{FirstGID: 5, Name: "tiles", TileCount: 4}}},
+		{"without tilesets", nil},
+	}
+	for _, test := range tests {
+		m := Map{Tilesets: test.tilesets, Layers: []Layer{layer}}
+		warnings := m.Validate()
+		if len(warnings) != 1 || !errors.Is(warnings[0], ErrGIDRange) {
+			t.Errorf("Wrong warnings for a GID %v\nWanted: %v\nGot: %v", test.name, ErrGIDRange, warnings)
+		}
+	}
+}
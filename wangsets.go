@@ -1,20 +1,159 @@
 package tmx
 
+import (
+	"encoding/xml"
+	"strconv"
+	"strings"
+)
+
 // WangSet is a wang set from the TMX map
 type WangSet struct {
 	// Name is the name of the wang set
 	Name string `xml:"name,attr"`
-	// Tile is the local tile id of the wang set
-	Tile uint32 `xml:"id,attr"`
+	// Class is the class of the wang set
+	Class string `xml:"class,attr"`
+	// Type is corner, edge or mixed. Sets from before Tiled 1.5 don't have a
+	// type, so it's worked out from the colors they use.
+	Type string `xml:"type,attr"`
+	// Tile is the local tile id of the tile representing the wang set, or -1
+	// if there isn't one
+	Tile int `xml:"tile,attr"`
+	// Properties are the custom properties of the wang set
+	Properties Properties `xml:"properties>property"`
+	// Colors are the colors of the wang set. Color indexes in wang IDs start at
+	// 1 for the first color, and 0 means no color.
+	Colors []WangColor `xml:"wangcolor"`
 	// WangCornerColor is a color that can be used to define the corner of a
-	// Wang tile
+	// Wang tile. It is only used by wang sets from before Tiled 1.5, and is
+	// also added to Colors after any WangEdgeColors.
 	WangCornerColors []WangCornerColor `xml:"wangcornercolor"`
-	// WangEdgeColor is a color that can be used to define the edge of a Wang tile
+	// WangEdgeColor is a color that can be used to define the edge of a Wang
+	// tile. It is only used by wang sets from before Tiled 1.5, and is also
+	// added to the start of Colors.
 	WangEdgeColors []WangEdgeColor `xml:"wangedgecolor"`
 	// WangTile defines a Wang Tile
 	WangTiles []WangTile `xml:"wangtile"`
 }
 
+// UnmarshalXML implements the encoding/xml Unmarshaler interface
+func (w *WangSet) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type wangSet WangSet
+	ws := wangSet{
+		Tile: -1,
+	}
+	line := currentLine(d)
+	if err := d.DecodeElement(&ws, &start); err != nil {
+		return errorAt(err, elementPath("wangset", ws.Name), line)
+	}
+	*w = (WangSet)(ws)
+	w.convertLegacy()
+	return nil
+}
+
+// convertLegacy turns the separate corner and edge colors of wang sets from
+// before Tiled 1.5 into Colors, with the edge colors first, and moves the
+// corner color indexes of the wang tiles to match
+func (w *WangSet) convertLegacy() {
+	if len(w.Colors) > 0 || len(w.WangCornerColors)+len(w.WangEdgeColors) == 0 {
+		return
+	}
+	for _, c := range w.WangEdgeColors {
+		w.Colors = append(w.Colors, WangColor{Name: c.Name, Color: c.Color, Tile: c.Tile, Probability: c.Probability})
+	}
+	for _, c := range w.WangCornerColors {
+		w.Colors = append(w.Colors, WangColor{Name: c.Name, Color: c.Color, Tile: c.Tile, Probability: c.Probability})
+	}
+	offset := uint8(len(w.WangEdgeColors))
+	for i := range w.WangTiles {
+		id := &w.WangTiles[i].WangID
+		for c := 1; c < len(id); c += 2 {
+			if id[c] != 0 {
+				id[c] += offset
+			}
+		}
+	}
+	if w.Type == "" {
+		switch {
+		case len(w.WangEdgeColors) == 0:
+			w.Type = "corner"
+		case len(w.WangCornerColors) == 0:
+			w.Type = "edge"
+		default:
+			w.Type = "mixed"
+		}
+	}
+}
+
+// MarshalXML implements the encoding/xml Marshaler interface. Wang sets are
+// always written in the format of Tiled 1.5 and later.
+func (w WangSet) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	a := attrs{}
+	a.add("name", w.Name)
+	a.str("class", w.Class, "")
+	a.str("type", w.Type, "")
+	a.add("tile", strconv.Itoa(w.Tile))
+	start, err := encodeStart(e, start, a)
+	if err != nil {
+		return err
+	}
+	if err = encodeProperties(e, w.Properties); err != nil {
+		return err
+	}
+	for _, c := range w.Colors {
+		if err = e.EncodeElement(c, startElement("wangcolor")); err != nil {
+			return err
+		}
+	}
+	for _, t := range w.WangTiles {
+		if err = e.EncodeElement(t, startElement("wangtile")); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// Color returns the color with the index used in wang IDs, starting at 1. It
+// returns false for 0 and indexes past the last color.
+func (w *WangSet) Color(index uint8) (WangColor, bool) {
+	if index == 0 || int(index) > len(w.Colors) {
+		return WangColor{}, false
+	}
+	return w.Colors[index-1], true
+}
+
+// WangColor is a color of a wang set, used on the corners and edges of its
+// tiles
+type WangColor struct {
+	// Name is the name of this color
+	Name string `xml:"name,attr"`
+	// Class is the class of this color
+	Class string `xml:"class,attr,omitempty"`
+	// Color is the color in #RRGGBB format
+	Color string `xml:"color,attr"`
+	// Tile is the tile ID of the tile representing this color, or -1 if there
+	// isn't one
+	Tile int `xml:"tile,attr"`
+	// Probability is the relative probability that this color is chosen
+	Probability float64 `xml:"probability,attr"`
+	// Properties are the custom properties of the color
+	Properties Properties `xml:"properties>property"`
+}
+
+// UnmarshalXML implements the encoding/xml Unmarshaler interface
+func (c *WangColor) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type wangColor WangColor
+	wc := wangColor{
+		Tile:        -1,
+		Probability: 1,
+	}
+	line := currentLine(d)
+	if err := d.DecodeElement(&wc, &start); err != nil {
+		return errorAt(err, elementPath("wangcolor", wc.Name), line)
+	}
+	*c = (WangColor)(wc)
+	return nil
+}
+
 // WangCornerColor is a color that can be used to define the edge of a Wang
 // tile
 type WangCornerColor struct {
@@ -22,8 +161,9 @@ type WangCornerColor struct {
 	Name string `xml:"name,attr"`
 	// Color is the color in #RRGGBB format
 	Color string `xml:"color,attr"`
-	// Tile is the tile ID of the tile representing this color
-	Tile uint32 `xml:"tile,attr"`
+	// Tile is the tile ID of the tile representing this color, or -1 if there
+	// isn't one
+	Tile int `xml:"tile,attr"`
 	// Probability is the relative probability that this color is chosen
 	Probability float64 `xml:"probability,attr"`
 }
@@ -34,8 +174,9 @@ type WangEdgeColor struct {
 	Name string `xml:"name,attr"`
 	// Color is the color in #RRGGBB format
 	Color string `xml:"color,attr"`
-	// Tile is the tile ID of the tile representing this color
-	Tile uint32 `xml:"tile,attr"`
+	// Tile is the tile ID of the tile representing this color, or -1 if there
+	// isn't one
+	Tile int `xml:"tile,attr"`
 	// Probability is the relative probability that this color is chosen
 	Probability float64 `xml:"probability,attr"`
 }
@@ -45,10 +186,140 @@ type WangEdgeColor struct {
 type WangTile struct {
 	// TileID is the tile ID
 	TileID uint32 `xml:"tileid,attr"`
-	// WangID is a 32-bit unsigned integer stored in the format 0xCECECECE where
-	// C is a corner color and each E is an edge color, from right to left
-	// clockwise, starting with the top edge.
-	// It's stored as a string here rather than a uint32 it's easer for the end
-	// user of the library to read / parse themselves.
-	WangID string `xml:"wangid,attr"`
+	// WangID is the colors of the corners and edges of the tile
+	WangID WangID `xml:"wangid,attr"`
+	// HFlip, VFlip and DFlip are whether the tile is flipped horizontally,
+	// vertically or diagonally. They are only used by wang sets from before
+	// Tiled 1.5, where the wang ID is that of the flipped tile.
+	HFlip bool `xml:"hflip,attr,omitempty"`
+	VFlip bool `xml:"vflip,attr,omitempty"`
+	DFlip bool `xml:"dflip,attr,omitempty"`
+}
+
+// Flipping returns the flipping flags of the wang tile, in the form used by
+// TileData
+func (t WangTile) Flipping() uint32 {
+	var f uint32
+	if t.HFlip {
+		f |= HorizontalFlipFlag
+	}
+	if t.VFlip {
+		f |= VerticalFlipFlag
+	}
+	if t.DFlip {
+		f |= DiagonalFlipFlag
+	}
+	return f
+}
+
+// The indexes of the corners and edges of a WangID
+const (
+	WangTop = iota
+	WangTopRight
+	WangRight
+	WangBottomRight
+	WangBottom
+	WangBottomLeft
+	WangLeft
+	WangTopLeft
+)
+
+// WangID is the color index of each edge and corner of a wang tile, in
+// clockwise order starting with the top edge. Even indexes are edges and odd
+// indexes are corners, and 0 means no color.
+//
+// Tiled 1.5 and later write wang IDs as 8 comma separated indexes. Earlier
+// versions write a 32-bit number in the format 0xCECECECE, with one hex digit
+// per index starting from the lowest digit. Both are read, and IDs are written
+// in the newer format.
+type WangID [8]uint8
+
+// ParseWangID parses a wang ID in either of the formats written by Tiled
+func ParseWangID(s string) (WangID, error) {
+	var id WangID
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		v, err := strconv.ParseUint(s[2:], 16, 32)
+		if err != nil {
+			return id, ErrInvalidWangID
+		}
+		for i := range id {
+			id[i] = uint8(v >> (4 * i) & 0xf)
+		}
+		return id, nil
+	}
+	parts := strings.Split(s, ",")
+	if len(parts) != len(id) {
+		return id, ErrInvalidWangID
+	}
+	for i, p := range parts {
+		v, err := strconv.ParseUint(strings.TrimSpace(p), 10, 8)
+		if err != nil {
+			return id, ErrInvalidWangID
+		}
+		id[i] = uint8(v)
+	}
+	return id, nil
+}
+
+// String returns the wang ID as comma separated indexes
+func (w WangID) String() string {
+	parts := make([]string, len(w))
+	for i, c := range w {
+		parts[i] = strconv.Itoa(int(c))
+	}
+	return strings.Join(parts, ",")
+}
+
+// MarshalText implements the encoding.TextMarshaler interface
+func (w WangID) MarshalText() ([]byte, error) {
+	return []byte(w.String()), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface
+func (w *WangID) UnmarshalText(b []byte) error {
+	id, err := ParseWangID(string(b))
+	if err != nil {
+		return err
+	}
+	*w = id
+	return nil
+}
+
+// Edge returns the color index of the edge, where 0 is the top edge and the
+// edges go clockwise
+func (w WangID) Edge(i int) uint8 {
+	return w[i*2]
+}
+
+// Corner returns the color index of the corner, where 0 is the top right
+// corner and the corners go clockwise
+func (w WangID) Corner(i int) uint8 {
+	return w[i*2+1]
+}
+
+// Flip returns the wang ID of a tile after it is flipped with the flipping
+// flags. Like the tiles, the diagonal flip is done before the horizontal and
+// vertical flips.
+func (w WangID) Flip(flags uint32) WangID {
+	if flags&DiagonalFlipFlag != 0 {
+		w = w.permute(6)
+	}
+	if flags&HorizontalFlipFlag != 0 {
+		w = w.permute(8)
+	}
+	if flags&VerticalFlipFlag != 0 {
+		w = w.permute(12)
+	}
+	return w
+}
+
+// permute mirrors the wang ID, moving the index at i to n-i. The diagonal,
+// horizontal and vertical flips are n of 6, 8 and 12.
+func (w WangID) permute(n int) WangID {
+	var p WangID
+	for i := range w {
+		p[(n-i+len(w))%len(w)] = w[i]
+	}
+	return p
 }
@@ -0,0 +1,146 @@
+package tmx
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestParseWangID(t *testing.T) {
+	exp := []struct {
+		s  string
+		id WangID
+	}{
+		{"0,1,0,2,0,1,0,2", WangID{0, 1, 0, 2, 0, 1, 0, 2}},
+		{"1, 2, 3, 4, 5, 6, 7, 255", WangID{1, 2, 3, 4, 5, 6, 7, 255}},
+		{"0x10121012", WangID{2, 1, 0, 1, 2, 1, 0, 1}},
+		{"0x0", WangID{}},
+	}
+	for _, e := range exp {
+		id, err := ParseWangID(e.s)
+		if err != nil {
+			t.Errorf("Unable to parse wang ID %v. Error was: %v", e.s, err)
+			continue
+		}
+		if id != e.id {
+			t.Errorf("Wang ID %v was parsed incorrectly\nWanted: %v\nGot: %v", e.s, e.id, id)
+		}
+	}
+	for _, s := range []string{"", "1,2,3", "0,0,0,0,0,0,0,256", "0xfffffffff", "a,b,c,d,e,f,g,h"} {
+		if _, err := ParseWangID(s); !errors.Is(err, ErrInvalidWangID) {
+			t.Errorf("Invalid wang ID %q was parsed\nWanted: %v\nGot: %v", s, ErrInvalidWangID, err)
+		}
+	}
+}
+
+func TestWangIDFlip(t *testing.T) {
+	// Only the top right corner and the top edge have colors
+	id := WangID{1, 2, 0, 0, 0, 0, 0, 0}
+	exp := map[uint32]WangID{
+		0:                                     id,
+		HorizontalFlipFlag:                    {1, 0, 0, 0, 0, 0, 0, 2},
+		VerticalFlipFlag:                      {0, 0, 0, 2, 1, 0, 0, 0},
+		DiagonalFlipFlag:                      {0, 0, 0, 0, 0, 2, 1, 0},
+		DiagonalFlipFlag | HorizontalFlipFlag: {0, 0, 1, 2, 0, 0, 0, 0},
+	}
+	for f, e := range exp {
+		if got := id.Flip(f); got != e {
+			t.Errorf("Wang ID flipped with %x was incorrect\nWanted: %v\nGot: %v", f, e, got)
+		}
+	}
+	if id.Edge(0) != 1 || id.Corner(0) != 2 {
+		t.Errorf("Top edge and top right corner were incorrect\nWanted: %v, %v\nGot: %v, %v", 1, 2, id.Edge(0), id.Corner(0))
+	}
+}
+
+func TestWangSets(t *testing.T) {
+	m, err := ParseFS(os.DirFS("testData"), "wangSets.tmx")
+	if err != nil {
+		t.Fatalf("Unable to parse wangSets.tmx. Error was: %v", err)
+	}
+	ts := m.Tilesets[0]
+	if len(ts.Transformations) != 1 || ts.Transformations[0].Rotate != 1 || ts.Transformations[0].PreferUntransformed != 1 {
+		t.Errorf("Tileset transformations were incorrect\nGot: %v", ts.Transformations)
+	}
+	ws := ts.WangSets[0]
+	if ws.Name != "Ground" || ws.Type != "corner" || ws.Tile != -1 {
+		t.Errorf("Wang set was incorrect\nGot: %v %v %v", ws.Name, ws.Type, ws.Tile)
+	}
+	if len(ws.Colors) != 2 || ws.Colors[1].Name != "Sand" || ws.Colors[1].Probability != 0.5 || ws.Colors[0].Tile != 0 {
+		t.Errorf("Wang colors were incorrect\nGot: %v", ws.Colors)
+	}
+	if v, _ := ws.Colors[0].Properties.Get("speed"); v.Value != "2" {
+		t.Errorf("Wang color properties were incorrect\nWanted: %v\nGot: %v", "2", v.Value)
+	}
+	if ws.WangTiles[2].WangID != (WangID{0, 2, 0, 1, 0, 1, 0, 1}) {
+		t.Errorf("Wang ID was incorrect\nWanted: %v\nGot: %v", WangID{0, 2, 0, 1, 0, 1, 0, 1}, ws.WangTiles[2].WangID)
+	}
+
+	legacy := m.Tilesets[1].WangSets[0]
+	if legacy.Type != "mixed" {
+		t.Errorf("Legacy wang set type was incorrect\nWanted: %v\nGot: %v", "mixed", legacy.Type)
+	}
+	names := []string{"Road", "River", "Grass"}
+	if len(legacy.Colors) != len(names) {
+		t.Fatalf("Legacy wang colors weren't converted\nWanted: %v\nGot: %v", names, legacy.Colors)
+	}
+	for i, n := range names {
+		if legacy.Colors[i].Name != n {
+			t.Errorf("Legacy wang color %v was incorrect\nWanted: %v\nGot: %v", i, n, legacy.Colors[i].Name)
+		}
+	}
+	exp := []WangID{
+		{0, 3, 0, 3, 0, 3, 0, 3},
+		{2, 3, 0, 3, 2, 3, 0, 3},
+		{0, 3, 2, 3, 0, 3, 2, 3},
+	}
+	for i, e := range exp {
+		if legacy.WangTiles[i].WangID != e {
+			t.Errorf("Legacy wang ID %v was incorrect\nWanted: %v\nGot: %v", i, e, legacy.WangTiles[i].WangID)
+		}
+	}
+	if legacy.WangTiles[2].Flipping() != DiagonalFlipFlag {
+		t.Errorf("Legacy wang tile flipping was incorrect\nWanted: %v\nGot: %v", DiagonalFlipFlag, legacy.WangTiles[2].Flipping())
+	}
+}
+
+func TestWangSetsJSON(t *testing.T) {
+	m, err := ParseFS(os.DirFS("testData"), "wangSets.tmj")
+	if err != nil {
+		t.Fatalf("Unable to parse wangSets.tmj. Error was: %v", err)
+	}
+	ts := m.Tilesets[0]
+	if len(ts.Transformations) != 1 || ts.Transformations[0].Rotate != 1 || ts.Transformations[0].HFlip != 0 {
+		t.Errorf("Tileset transformations were incorrect\nGot: %v", ts.Transformations)
+	}
+	ws := ts.WangSets[0]
+	if ws.Type != "corner" || len(ws.Colors) != 2 || ws.WangTiles[1].WangID != (WangID{0, 2, 0, 1, 0, 1, 0, 1}) {
+		t.Errorf("Wang set was incorrect\nGot: %v", ws)
+	}
+	legacy := ts.WangSets[1]
+	if legacy.Type != "mixed" || len(legacy.Colors) != 2 || legacy.Colors[1].Name != "Grass" {
+		t.Errorf("Legacy wang set wasn't converted\nGot: %v", legacy)
+	}
+	if id := legacy.WangTiles[0].WangID; id != (WangID{1, 2, 0, 2, 1, 2, 0, 2}) {
+		t.Errorf("Legacy wang ID was incorrect\nWanted: %v\nGot: %v", WangID{1, 2, 0, 2, 1, 2, 0, 2}, id)
+	}
+}
+
+func TestEncodeWangSets(t *testing.T) {
+	m, m2, _ := roundTrip(t, "wangSets.tmx")
+	for i := range m.Tilesets {
+		exp, got := m.Tilesets[i].WangSets[0], m2.Tilesets[i].WangSets[0]
+		if got.Type != exp.Type || len(got.Colors) != len(exp.Colors) || len(got.WangTiles) != len(exp.WangTiles) {
+			t.Errorf("Encoded wang set %v was incorrect\nWanted: %v\nGot: %v", exp.Name, exp, got)
+			continue
+		}
+		for j := range exp.WangTiles {
+			if got.WangTiles[j] != exp.WangTiles[j] {
+				t.Errorf("Encoded wang tile %v was incorrect\nWanted: %v\nGot: %v", j, exp.WangTiles[j], got.WangTiles[j])
+			}
+		}
+	}
+	if m2.Tilesets[0].Transformations[0] != m.Tilesets[0].Transformations[0] {
+		t.Errorf("Encoded transformations were incorrect\nWanted: %v\nGot: %v", m.Tilesets[0].Transformations, m2.Tilesets[0].Transformations)
+	}
+}